@@ -0,0 +1,61 @@
+package tail
+
+import "bytes"
+
+// stripANSI returns b without ANSI escape sequences: CSI sequences such as
+// SGR colors ("\x1b[1;31m"), OSC sequences such as window titles and
+// hyperlinks, ended by BEL or ST, and two-byte escapes. An unfinished
+// sequence at the end of b is removed as well. b itself is returned if it
+// holds no escape.
+func stripANSI(b []byte) []byte {
+	if bytes.IndexByte(b, 0x1b) < 0 {
+		return b
+	}
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); {
+		if b[i] != 0x1b {
+			out = append(out, b[i])
+			i++
+			continue
+		}
+		i = skipEscape(b, i+1)
+	}
+	return out
+}
+
+// skipEscape returns the index just past the escape sequence whose ESC
+// precedes b[i].
+func skipEscape(b []byte, i int) int {
+	if i == len(b) {
+		return i
+	}
+	switch b[i] {
+	case '[': // CSI: parameters, intermediates, final byte
+		i++
+		for i < len(b) && b[i] >= 0x20 && b[i] <= 0x3f {
+			i++
+		}
+		if i < len(b) && b[i] >= 0x40 && b[i] <= 0x7e {
+			i++
+		}
+		return i
+	case ']': // OSC: ended by BEL or ST (ESC \)
+		for i++; i < len(b); i++ {
+			if b[i] == 0x07 {
+				return i + 1
+			}
+			if b[i] == 0x1b && i+1 < len(b) && b[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return i
+	default: // intermediates, then a final byte
+		for i < len(b) && b[i] >= 0x20 && b[i] <= 0x2f {
+			i++
+		}
+		if i < len(b) && b[i] >= 0x30 && b[i] <= 0x7e {
+			i++
+		}
+		return i
+	}
+}
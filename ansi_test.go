@@ -0,0 +1,38 @@
+package tail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripANSI(t *testing.T) {
+	for in, want := range map[string]string{
+		"plain":                                    "plain",
+		"\x1b[31merror\x1b[0m":                     "error",
+		"\x1b[1;38;5;208mbold orange\x1b[m":        "bold orange",
+		"a\x1b[2Kb\x1b[10;20Hc":                    "abc",
+		"\x1b[?25lhidden cursor\x1b[?25h":          "hidden cursor",
+		"\x1b]0;title\x07text":                     "text",
+		"\x1b]8;;http://x\x1b\\link\x1b]8;;\x1b\\": "link",
+		"\x1b(Bcharset\x1b=":                       "charset",
+		"cut \x1b[3":                               "cut ",
+		"lone \x1b":                                "lone ",
+		"né \x1b[32m✓\x1b[0m":                      "né ✓",
+	} {
+		if got := string(stripANSI([]byte(in))); got != want {
+			t.Errorf("stripANSI(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTail_StripANSI(t *testing.T) {
+	raw := "\x1b[31mred\x1b[0m\n"
+	tailer, err := TailReader(strings.NewReader(raw), Config{StripANSI: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	line := <-tailer.Lines
+	eq(t, line.Text, "red")
+	eq(t, string(line.Bytes), strings.TrimSuffix(raw, "\n"))
+	eq(t, line.Offset, int64(len(raw)))
+}
@@ -0,0 +1,177 @@
+package tail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PositionStore persists the position of a Tail so that a later tail can
+// resume from it via Config.Location.
+type PositionStore interface {
+	// SavePosition records the position just past the last delivered line.
+	SavePosition(pos SeekInfo) error
+}
+
+// CheckpointFile is a PositionStore that keeps the position in the file at
+// Path, as set up by Config.CheckpointPath. Each position is written to a
+// temporary file in the same directory that then replaces Path, so that a
+// crash leaves either the old or the new position, never a partial one.
+type CheckpointFile struct {
+	Path string
+}
+
+// checkpointJSON is the content of a CheckpointFile.
+type checkpointJSON struct {
+	Offset         int64  `json:"offset"`
+	Whence         int    `json:"whence"`
+	FileIdentifier string `json:"file_identifier,omitempty"`
+}
+
+// SavePosition implements PositionStore.
+func (c CheckpointFile) SavePosition(pos SeekInfo) error {
+	b, err := json.Marshal(checkpointJSON{Offset: pos.Offset, Whence: pos.Whence, FileIdentifier: pos.FileIdentifier})
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(c.Path), filepath.Base(c.Path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.Path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// LoadPosition returns the position saved last, or nil if none has been.
+func (c CheckpointFile) LoadPosition() (*SeekInfo, error) {
+	b, err := os.ReadFile(c.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ck checkpointJSON
+	if err := json.Unmarshal(b, &ck); err != nil {
+		return nil, fmt.Errorf("tail: invalid checkpoint %s: %w", c.Path, err)
+	}
+	return &SeekInfo{Offset: ck.Offset, Whence: ck.Whence, FileIdentifier: ck.FileIdentifier}, nil
+}
+
+// useCheckpointFile sets up CheckpointPath: PositionStore saves to it and,
+// unless told where to start, tailing resumes from the position saved there.
+func (config *Config) useCheckpointFile() error {
+	if config.CheckpointPath == "" {
+		return nil
+	}
+	store := CheckpointFile{Path: config.CheckpointPath}
+	config.PositionStore = store
+	if config.Location != nil || config.SeekEnd || config.SeekTime != nil {
+		return nil
+	}
+	pos, err := store.LoadPosition()
+	config.Location = pos
+	return err
+}
+
+// Position returns the position just past the last line sent on Lines in
+// the file being read, to be saved and passed back as Config.Location. Unlike
+// Line.Offset it is also available while no lines arrive, and moves to the
+// start of a new file as soon as it is opened. It is safe to call while
+// tailing, and returns an error before the file has been opened.
+//
+// Tell is named after ftell and reports the file offset instead, which may
+// be ahead of the lines sent.
+func (tail *Tail) Position() (SeekInfo, error) {
+	if child := tail.newestTail(); child != nil {
+		return child.Position()
+	}
+	tail.ckLk.Lock()
+	defer tail.ckLk.Unlock()
+	if !tail.positionSet {
+		return SeekInfo{}, errNotOpened
+	}
+	return tail.position, nil
+}
+
+var errNotOpened = errors.New("tail: file not opened yet")
+
+// setPosition moves the position to offset in the current file without
+// counting a delivered line, after an open or seek.
+func (tail *Tail) setPosition(offset int64) {
+	tail.ckLk.Lock()
+	defer tail.ckLk.Unlock()
+	tail.position = SeekInfo{Offset: offset, Whence: 0, FileIdentifier: tail.fileIdentifier}
+	tail.positionSet = true
+}
+
+// recordPosition notes that the line ending at offset has been delivered and
+// writes a checkpoint once CheckpointEveryNLines lines have been delivered
+// since the last one.
+func (tail *Tail) recordPosition(offset int64) {
+	tail.ckLk.Lock()
+	defer tail.ckLk.Unlock()
+	tail.position = SeekInfo{Offset: offset, Whence: 0, FileIdentifier: tail.fileIdentifier}
+	tail.positionSet = true
+	if tail.PositionStore == nil {
+		return
+	}
+	tail.positionDirty = true
+	tail.linesSinceCheckpoint++
+	if tail.CheckpointEveryNLines > 0 && tail.linesSinceCheckpoint >= tail.CheckpointEveryNLines {
+		tail.saveCheckpoint()
+	}
+}
+
+// checkpoint writes the current position if it changed since the last
+// checkpoint.
+func (tail *Tail) checkpoint() {
+	if tail.PositionStore == nil {
+		return
+	}
+
+	tail.ckLk.Lock()
+	defer tail.ckLk.Unlock()
+	if tail.positionDirty {
+		tail.saveCheckpoint()
+	}
+}
+
+// saveCheckpoint must be called with ckLk held.
+func (tail *Tail) saveCheckpoint() {
+	if err := tail.PositionStore.SavePosition(tail.position); err != nil {
+		tail.Logger.Printf("Failed to checkpoint position of %s: %s", tail.Filename, err)
+		return
+	}
+	tail.positionDirty = false
+	tail.linesSinceCheckpoint = 0
+}
+
+// checkpointEvery writes a checkpoint every CheckpointInterval until the tail
+// dies.
+func (tail *Tail) checkpointEvery() {
+	for {
+		timer, stop := tail.clock.NewTimer(tail.CheckpointInterval)
+		select {
+		case <-timer:
+			tail.checkpoint()
+		case <-tail.Dying():
+			stop()
+			return
+		}
+	}
+}
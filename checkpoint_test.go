@@ -0,0 +1,122 @@
+package tail
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakePositionStore struct {
+	mu    sync.Mutex
+	saved []SeekInfo
+}
+
+func (s *fakePositionStore) SavePosition(pos SeekInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, pos)
+	return nil
+}
+
+func (s *fakePositionStore) offsets() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offsets := []int64{}
+	for _, pos := range s.saved {
+		offsets = append(offsets, pos.Offset)
+	}
+	return offsets
+}
+
+func TestTail_CheckpointEveryNLines(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("a\nb\nc\n")
+
+	store := &fakePositionStore{}
+	tailer, err := TailFile(testFile, Config{Follow: true, PositionStore: store, CheckpointInterval: time.Hour, CheckpointEveryNLines: 2, Logger: DiscardingLogger})
+	noError(t, err)
+
+	for i := 0; i < 3; i++ {
+		recvLine(t, tailer)
+	}
+	eq(t, store.offsets(), []int64{4})
+
+	cleanTailer(tailer)
+	eq(t, store.offsets(), []int64{4, 6})
+	eq(t, store.saved[1].FileIdentifier, store.saved[0].FileIdentifier)
+}
+
+func TestTail_CheckpointInterval(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("a\n")
+
+	store := &fakePositionStore{}
+	tailer, err := TailFile(testFile, Config{Follow: true, PositionStore: store, CheckpointInterval: 20 * time.Millisecond, Logger: DiscardingLogger})
+	noError(t, err)
+
+	recvLine(t, tailer)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(store.offsets()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	eq(t, store.offsets(), []int64{2})
+
+	// Nothing new was delivered, so the interval does not repeat it.
+	time.Sleep(50 * time.Millisecond)
+	eq(t, store.offsets(), []int64{2})
+
+	f.WriteString("b\n")
+	recvLine(t, tailer)
+	cleanTailer(tailer)
+	eq(t, store.offsets()[len(store.offsets())-1], int64(4))
+}
+
+func TestCheckpointFile(t *testing.T) {
+	dir := t.TempDir()
+	store := CheckpointFile{Path: filepath.Join(dir, "pos.json")}
+	pos, err := store.LoadPosition()
+	noError(t, err)
+	if pos != nil {
+		t.Fatalf("expected no position, got %+v", pos)
+	}
+
+	noError(t, store.SavePosition(SeekInfo{Offset: 4, FileIdentifier: "1:2"}))
+	noError(t, store.SavePosition(SeekInfo{Offset: 6, FileIdentifier: "1:2"}))
+	pos, err = store.LoadPosition()
+	noError(t, err)
+	eq(t, *pos, SeekInfo{Offset: 6, FileIdentifier: "1:2"})
+
+	// No temporary file is left behind.
+	entries, err := os.ReadDir(dir)
+	noError(t, err)
+	eq(t, len(entries), 1)
+
+	noError(t, os.WriteFile(store.Path, []byte("{"), 0600))
+	if _, err := store.LoadPosition(); err == nil {
+		t.Fatal("expected an error for a corrupt checkpoint")
+	}
+}
+
+func TestTail_CheckpointPath(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("a\nb\n")
+	config := Config{Follow: true, CheckpointPath: filepath.Join(t.TempDir(), "pos.json"), Logger: DiscardingLogger}
+
+	tailer, err := TailFile(testFile, config)
+	noError(t, err)
+	eq(t, recvLine(t, tailer).Text, "a")
+	eq(t, recvLine(t, tailer).Text, "b")
+	cleanTailer(tailer)
+
+	// A restart resumes after the last line delivered.
+	f.WriteString("c\n")
+	tailer, err = TailFile(testFile, config)
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, recvLine(t, tailer).Text, "c")
+}
@@ -0,0 +1,192 @@
+package tail
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	c := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch, _ := c.NewTimer(d)
+	return ch
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, t)
+	c.cond.Broadcast()
+	return t.c, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, w := range c.waiters {
+			if w == t {
+				c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Advance moves the clock forward by d, firing the timers that expire.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.c <- c.now
+	}
+	c.waiters = waiters
+}
+
+// BlockUntil waits until n timers are pending.
+func (c *fakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+func TestTail_FakeClockIdleTimeout(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	clock := newFakeClock()
+	tailer, err := TailFile(testFile, Config{Follow: true, IdleTimeout: time.Hour, clock: clock, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	eq(t, recvLine(t, tailer).Text, "hello")
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	for range tailer.Lines {
+	}
+	if err := tailer.Wait(); !errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("expected ErrIdleTimeout, got %v", err)
+	}
+}
+
+func TestTail_FakeClockRateLimit(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+	tailer, err := TailReader(strings.NewReader("1\n2\n3\n"), Config{RateLimit: 0.5, clock: clock, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "1")
+	eq(t, line.Time, start)
+	for _, text := range []string{"2", "3"} {
+		// Lines are sent two seconds apart.
+		clock.BlockUntil(1)
+		select {
+		case line := <-tailer.Lines:
+			t.Fatalf("line %q sent before its time", line.Text)
+		default:
+		}
+		clock.Advance(2 * time.Second)
+		eq(t, recvLine(t, tailer).Text, text)
+	}
+}
+
+func TestTail_FakeClockCheckpointInterval(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("a\n")
+
+	clock := newFakeClock()
+	store := &fakePositionStore{}
+	tailer, err := TailFile(testFile, Config{Follow: true, PositionStore: store, CheckpointInterval: time.Hour, clock: clock, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	recvLine(t, tailer)
+
+	clock.BlockUntil(1)
+	eq(t, store.offsets(), []int64{})
+	clock.Advance(time.Hour)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(store.offsets()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	eq(t, store.offsets(), []int64{2})
+}
+
+func TestTail_FakeClockPolling(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("a\n")
+
+	clock := newFakeClock()
+	tailer, err := TailFile(testFile, Config{Follow: true, Poll: true, PollInterval: time.Hour, clock: clock, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, recvLine(t, tailer).Text, "a")
+
+	f.WriteString("b\n")
+	clock.BlockUntil(1)
+	select {
+	case line := <-tailer.Lines:
+		t.Fatalf("line %q sent before the next poll", line.Text)
+	case <-time.After(50 * time.Millisecond):
+	}
+	clock.Advance(time.Hour)
+	eq(t, recvLine(t, tailer).Text, "b")
+}
+
+func TestTail_FakeClockPollIntervalRetries(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "dir.log")
+	noError(t, os.Mkdir(testFile, 0755))
+
+	clock := newFakeClock()
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, PollInterval: time.Millisecond, clock: clock, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	if line := recvLine(t, tailer); !errors.Is(line.Err, ErrNotRegularFile) {
+		t.Fatalf("expected ErrNotRegularFile, got %v", line.Err)
+	}
+
+	// The open is retried after PollInterval, not POLL_DURATION.
+	clock.BlockUntil(1)
+	noError(t, os.Remove(testFile))
+	noError(t, os.WriteFile(testFile, []byte("hello\n"), 0600))
+	clock.Advance(time.Millisecond)
+	eq(t, recvLine(t, tailer).Text, "hello")
+}
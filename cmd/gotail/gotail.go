@@ -13,7 +13,7 @@ import (
 )
 
 func args2config() (tail.Config, int64) {
-	config := tail.Config{Follow: true}
+	config := tail.Config{Follow: true, Logger: tail.DefaultLogger}
 	n := int64(0)
 	maxlinesize := 0
 	flag.Int64Var(&n, "n", 0, "tail from the last Nth location")
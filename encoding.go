@@ -0,0 +1,89 @@
+package tail
+
+import (
+	"bufio"
+	"unicode/utf16"
+)
+
+// Encoding is the character encoding of the tailed file.
+type Encoding int
+
+const (
+	UTF8    Encoding = iota // read lines as is
+	UTF16LE                 // little-endian UTF-16, as written by Windows
+	UTF16BE                 // big-endian UTF-16
+)
+
+// readUTF16Line reads a line of UTF-16 code units ending with the
+// delimiter's code unit and returns it decoded to UTF-8. The number of
+// bytes read counts the raw UTF-16 bytes, so offsets stay file offsets. A
+// byte order mark at the start of the file is dropped.
+func (tail *Tail) readUTF16Line() ([]byte, int64, bool, error) {
+	delim := tail.delimiter()
+	var raw []byte
+	var err error
+
+	tail.lk.Lock()
+	for {
+		var chunk []byte
+		chunk, err = tail.reader.ReadSlice(delim)
+		raw = append(raw, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			break
+		}
+		if ends, highByte := tail.endsUTF16Line(raw); ends {
+			if highByte {
+				raw = append(raw, 0)
+			}
+			break
+		}
+	}
+	tail.lk.Unlock()
+
+	read := int64(len(raw))
+	if err == nil {
+		tail.rawRead(raw)
+	}
+
+	units := make([]uint16, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		if tail.Encoding == UTF16BE {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		} else {
+			units = append(units, uint16(raw[i])|uint16(raw[i+1])<<8)
+		}
+	}
+	if tail.offset == 0 && len(units) > 0 && units[0] == 0xfeff {
+		units = units[1:]
+	}
+	if err == nil && !tail.KeepDelimiter {
+		units = units[:len(units)-1]
+		if tail.TrimCR && len(units) > 0 && units[len(units)-1] == '\r' {
+			units = units[:len(units)-1]
+		}
+	}
+	return []byte(string(utf16.Decode(units))), read, false, err
+}
+
+// endsUTF16Line reports whether raw, which ends with the delimiter byte,
+// ends with the delimiter's code unit. For little-endian input the zero
+// high byte of the code unit follows raw; it is then consumed from the
+// reader and highByte is true.
+func (tail *Tail) endsUTF16Line(raw []byte) (ends, highByte bool) {
+	n := len(raw)
+	if tail.Encoding == UTF16BE {
+		return n%2 == 0 && raw[n-2] == 0, false
+	}
+	if n%2 == 0 {
+		return false, false
+	}
+	next, err := tail.reader.Peek(1)
+	if err != nil || next[0] != 0 {
+		return false, false
+	}
+	_, _ = tail.reader.Discard(1)
+	return true, true
+}
@@ -0,0 +1,43 @@
+package tail
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+func encodeUTF16(s string, order binary.ByteOrder) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		order.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+func TestTail_EncodingUTF16(t *testing.T) {
+	for _, tc := range []struct {
+		encoding Encoding
+		order    binary.ByteOrder
+	}{
+		{UTF16LE, binary.LittleEndian},
+		{UTF16BE, binary.BigEndian},
+	} {
+		testFile, f := testFile(t)
+		defer f.Close()
+		// U+0A0A has 0x0A in both bytes, which must not end a line.
+		f.Write(encodeUTF16("\ufeffhélloਊ\nwörld\n", tc.order))
+
+		tailer, err := TailFile(testFile, Config{Encoding: tc.encoding, Logger: DiscardingLogger})
+		noError(t, err)
+		defer tailer.Cleanup()
+
+		line := recvLine(t, tailer)
+		eq(t, line.Text, "hélloਊ")
+		eq(t, line.Offset, int64(16))
+		line = recvLine(t, tailer)
+		eq(t, line.Text, "wörld")
+		eq(t, line.Offset, int64(28))
+		noError(t, tailer.Wait())
+	}
+}
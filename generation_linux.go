@@ -0,0 +1,24 @@
+//go:build linux
+
+package tail
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// fsIocGetversion is FS_IOC_GETVERSION, _IOR('v', 1, long). It differs from
+// FS_IOC_GETFLAGS, _IOR('f', 1, long), only in the ioctl type byte, which
+// keeps the direction and size encoding correct on every architecture.
+const fsIocGetversion = unix.FS_IOC_GETFLAGS - 'f'<<8 + 'v'<<8
+
+// inodeGeneration returns the generation number of the file's inode. It
+// reports false when the filesystem does not support FS_IOC_GETVERSION.
+func inodeGeneration(f *os.File) (uint32, bool) {
+	gen, err := unix.IoctlGetUint32(int(f.Fd()), fsIocGetversion)
+	if err != nil {
+		return 0, false
+	}
+	return gen, true
+}
@@ -0,0 +1,74 @@
+package tail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestTail_InodeGenerationIdentifier(t *testing.T) {
+	testDir := t.TempDir()
+	name := filepath.Join(testDir, "test.log")
+
+	open := func() (string, uint64) {
+		t.Helper()
+		tail := &Tail{Filename: name, Config: Config{UseInodeGeneration: true}}
+		noError(t, tail.openFile())
+		defer tail.closeFile()
+		if _, ok := inodeGeneration(tail.file); !ok {
+			t.Skip("filesystem does not expose inode generation numbers")
+		}
+		fi, err := tail.file.Stat()
+		noError(t, err)
+		return tail.fileIdentifier, fi.Sys().(*syscall.Stat_t).Ino
+	}
+
+	for i := 0; i < 100; i++ {
+		f, err := os.Create(name)
+		noError(t, err)
+		f.Close()
+		oldID, oldIno := open()
+
+		noError(t, os.Remove(name))
+		f, err = os.Create(name)
+		noError(t, err)
+		f.Close()
+		newID, newIno := open()
+		noError(t, os.Remove(name))
+
+		if newIno == oldIno {
+			if newID == oldID {
+				t.Fatalf("identifier %q unchanged after inode %d was recycled", newID, newIno)
+			}
+			return
+		}
+	}
+	t.Skip("no inode was recycled")
+}
+
+func TestTail_CatchUpRotatedInodeGeneration(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "app.log")
+	noError(t, os.WriteFile(testFile, []byte("live\n"), 0600))
+	noError(t, os.WriteFile(testFile+".1", []byte("one\n"), 0600))
+
+	f, err := os.Open(testFile + ".1")
+	noError(t, err)
+	gen, ok := inodeGeneration(f)
+	id, idErr := FileIdentifier(f)
+	f.Close()
+	if !ok {
+		t.Skip("filesystem does not expose inode generation numbers")
+	}
+	noError(t, idErr)
+
+	tailer, err := TailFile(testFile, Config{CatchUpRotated: true, UseInodeGeneration: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "one")
+	eq(t, line.FileIdentifier, fmt.Sprintf("%s:%d", id, gen))
+	for range tailer.Lines {
+	}
+}
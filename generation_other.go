@@ -0,0 +1,10 @@
+//go:build !linux
+
+package tail
+
+import "os"
+
+// inodeGeneration is only supported on Linux.
+func inodeGeneration(f *os.File) (uint32, bool) {
+	return 0, false
+}
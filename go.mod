@@ -5,7 +5,6 @@ go 1.19
 require (
 	github.com/fsnotify/fsnotify v1.6.0
 	golang.org/x/sync v0.5.0
+	golang.org/x/sys v0.5.0
 	gopkg.in/tomb.v1 v1.0.0-20141024135613-dd632973f1e7
 )
-
-require golang.org/x/sys v0.5.0 // indirect
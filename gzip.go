@@ -0,0 +1,138 @@
+package tail
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// gzipReplay reports whether the file is a gzip archive to be replayed
+// decompressed, as set by Gzip, which Validate rules out with Follow.
+func (tail *Tail) gzipReplay() bool {
+	return tail.Gzip
+}
+
+// openGzip starts decompressing the file from its beginning. Line offsets
+// then count decompressed bytes, so Location is ignored.
+func (tail *Tail) openGzip() error {
+	if _, err := tail.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek error on %s: %s", tail.Filename, err)
+	}
+	tail.offset = 0
+	gz, err := tail.newGzipReader(tail.file, tail.Filename)
+	if err != nil {
+		return fmt.Errorf("error opening gzip %s: %w", tail.Filename, err)
+	}
+	tail.gz = gz
+	return nil
+}
+
+// newGzipReader starts decompressing r, read from the file name, and passes
+// the Extra field of its header to OnGzipHeader.
+func (tail *Tail) newGzipReader(r io.Reader, name string) (*gzip.Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err == nil && tail.OnGzipHeader != nil {
+		tail.OnGzipHeader(name, gz.Header.Extra)
+	}
+	return gz, err
+}
+
+// truncatedGzip handles a read error while replaying a gzip archive. If the
+// archive was cut short and TolerateTruncatedGzip is set, the partially
+// decompressed line is sent, followed by a single error Line, and true is
+// returned so that replay ends without error.
+func (tail *Tail) truncatedGzip(line []byte, err error) bool {
+	if tail.gz == nil || !tail.TolerateTruncatedGzip || !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false
+	}
+	if len(line) > 0 {
+		tail.rawRead(line)
+		tail.sendPartial(line, false)
+	}
+	err = fmt.Errorf("truncated gzip archive %s: %w", tail.Filename, err)
+	tail.send(&Line{Time: tail.clock.Now(), Err: err, Offset: tail.offset, FileIdentifier: tail.fileIdentifier})
+	return true
+}
+
+// drainCompressedRotation sends the lines past the current offset from the
+// compressed copy of the rotated file, if ReadCompressedRotations is set
+// and <name>.1.gz exists. A copy older than the last write to the file is
+// from an earlier rotation and is ignored. It returns false if tailing has
+// stopped meanwhile.
+func (tail *Tail) drainCompressedRotation() bool {
+	if !tail.ReadCompressedRotations {
+		return true
+	}
+	name := tail.Filename + ".1.gz"
+	gzFi, err := os.Stat(name)
+	if err != nil {
+		return true
+	}
+	if fi, err := tail.file.Stat(); err == nil && gzFi.ModTime().Before(fi.ModTime()) {
+		return true
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		tail.logEvent("read_error", err, "Failed to open %s: %s", name, err)
+		return true
+	}
+	defer f.Close()
+	gz, err := tail.newGzipReader(f, name)
+	if err != nil {
+		tail.logEvent("read_error", err, "Failed to read %s: %s", name, err)
+		return true
+	}
+	span := tail.startReplay(name)
+	defer span.End()
+	if _, err := io.CopyN(io.Discard, gz, tail.offset); err != nil {
+		span.SetAttr(AttrError, err.Error())
+		tail.logEvent("read_error", err, "Skipping %s: %s", name, err)
+		return true
+	}
+
+	return tail.sendRemaining(tail.newReader(gz), name)
+}
+
+// sendRemaining sends the lines left in reader, read from the file name,
+// like those of the file being tailed, advancing the offset. The file is
+// complete, so a final line without a delimiter is sent as is. It returns
+// false if tailing has stopped, MaxBytes having been reached included.
+func (tail *Tail) sendRemaining(reader *bufio.Reader, name string) bool {
+	tail.lk.Lock()
+	saved := tail.reader
+	tail.reader = reader
+	tail.lk.Unlock()
+	defer func() {
+		tail.lk.Lock()
+		tail.reader = saved
+		tail.lk.Unlock()
+	}()
+
+	for {
+		select {
+		case <-tail.Dying():
+			if tail.Err() != errStopAtEOF {
+				return false
+			}
+		default:
+		}
+		line, numRead, truncated, err := tail.readLine()
+		if err == nil {
+			if _, ok := tail.sendRead(line, numRead, truncated); !ok {
+				return false
+			}
+			continue
+		}
+		tail.flushRepeat()
+		if err != io.EOF {
+			tail.logEvent("read_error", err, "Error reading %s: %s", name, err)
+		} else if len(line) > 0 && !tail.sendFinal(line, truncated) {
+			return false
+		}
+		return true
+	}
+}
@@ -0,0 +1,178 @@
+package tail
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTail_TolerateTruncatedGzip(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.log.gz")
+	f, err := os.Create(testFile)
+	noError(t, err)
+	defer f.Close()
+
+	// Flush after the first half so it is decodable on its own, then cut
+	// the archive short in the middle of the second half.
+	gz := gzip.NewWriter(f)
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(gz, "line %d\n", i)
+	}
+	noError(t, gz.Flush())
+	fi, err := f.Stat()
+	noError(t, err)
+	for i := 100; i < 200; i++ {
+		fmt.Fprintf(gz, "line %d\n", i)
+	}
+	noError(t, gz.Close())
+	noError(t, f.Truncate(fi.Size()+10))
+
+	tailer, err := TailFile(testFile, Config{Gzip: true, TolerateTruncatedGzip: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	var n int
+	var last *Line
+	for line := range tailer.Lines {
+		if line.Err != nil {
+			last = line
+			continue
+		}
+		if last != nil {
+			t.Fatalf("line %q after error marker", line.Text)
+		}
+		eq(t, line.Text, fmt.Sprintf("line %d", n))
+		n++
+	}
+	noError(t, tailer.Wait())
+	if n < 100 {
+		t.Fatalf("expected at least the flushed 100 lines, got %d", n)
+	}
+	if last == nil || !errors.Is(last.Err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected truncation error marker, got %v", last)
+	}
+}
+
+func TestTail_ReadCompressedRotations(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, ReadCompressedRotations: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, recvLine(t, tailer).Text, "one")
+
+	// "two" was copied and compressed before the file was truncated, so
+	// it can only be read from the compressed copy.
+	gzFile, err := os.Create(testFile + ".1.gz")
+	noError(t, err)
+	gz := gzip.NewWriter(gzFile)
+	gz.Write([]byte("one\ntwo\n"))
+	noError(t, gz.Close())
+	noError(t, gzFile.Close())
+	future := time.Now().Add(time.Hour)
+	noError(t, os.Chtimes(testFile+".1.gz", future, future))
+	noError(t, f.Truncate(0))
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "two")
+	eq(t, line.Offset, int64(8))
+
+	f.Seek(0, io.SeekStart)
+	f.WriteString("three\n")
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "three")
+	eq(t, line.Offset, int64(6))
+}
+
+func TestTail_ReadCompressedRotationsTruncate(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, ReadCompressedRotations: true, MaxLineSize: 10, TruncateLongLines: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, recvLine(t, tailer).Text, "one")
+
+	long := strings.Repeat("x", 50)
+	gzFile, err := os.Create(testFile + ".1.gz")
+	noError(t, err)
+	gz := gzip.NewWriter(gzFile)
+	gz.Write([]byte("one\n" + long + "\n"))
+	noError(t, gz.Close())
+	noError(t, gzFile.Close())
+	future := time.Now().Add(time.Hour)
+	noError(t, os.Chtimes(testFile+".1.gz", future, future))
+	noError(t, f.Truncate(0))
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, long[:10])
+	eq(t, line.Truncated, true)
+	eq(t, line.Offset, int64(55))
+}
+
+func TestTail_Gzip(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		gzip bool
+	}{{"app.log.gz", true}, {"app.log.archive", true}, {"app.log.gz", false}} {
+		testFile := filepath.Join(t.TempDir(), tc.name)
+		f, err := os.Create(testFile)
+		noError(t, err)
+		gz := gzip.NewWriter(f)
+		fmt.Fprint(gz, "one\ntwo\nthree")
+		noError(t, gz.Close())
+		noError(t, f.Close())
+
+		tailer, err := TailFile(testFile, Config{Gzip: tc.gzip, Logger: DiscardingLogger})
+		noError(t, err)
+
+		var got []string
+		var offsets []int64
+		for line := range tailer.Lines {
+			got = append(got, line.Text)
+			offsets = append(offsets, line.Offset)
+		}
+		noError(t, tailer.Wait())
+		tailer.Cleanup()
+		if !tc.gzip {
+			// Without Gzip the archive is read as is.
+			if len(got) == 0 || got[0] == "one" {
+				t.Fatalf("%s was decompressed without Gzip: %q", tc.name, got)
+			}
+			continue
+		}
+		eq(t, got, []string{"one", "two", "three"})
+		eq(t, offsets, []int64{4, 8, 8})
+	}
+}
+
+func TestTail_OnGzipHeader(t *testing.T) {
+	testFile, f := testFile(t)
+	f.WriteString("hello\n")
+	f.Close()
+	lastTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	compressFile(t, testFile, lastTime)
+
+	var names []string
+	var meta rotateFileMetadata
+	tailer, err := TailFile(testFile+".gz", Config{Gzip: true, Logger: DiscardingLogger, OnGzipHeader: func(name string, extra []byte) {
+		names = append(names, name)
+		noError(t, json.Unmarshal(extra, &meta))
+	}})
+	noError(t, err)
+	defer tailer.Cleanup()
+	eq(t, recvLine(t, tailer).Text, "hello")
+	noError(t, tailer.Wait())
+	eq(t, names, []string{testFile + ".gz"})
+	eq(t, meta.LastTime, lastTime)
+}
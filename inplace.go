@@ -0,0 +1,43 @@
+package tail
+
+import "hash/crc32"
+
+// resetWindow is how many bytes before the read position DetectInPlaceReset
+// checksums.
+const resetWindow = 64
+
+// resetSum is the checksum of the bytes before offset, see
+// Config.DetectInPlaceReset.
+type resetSum struct {
+	offset int64
+	sum    uint32
+	set    bool
+}
+
+// resetInPlace reports whether the bytes before the read position have
+// changed since they were last checksummed. The first call at a new
+// position only records their checksum.
+func (tail *Tail) resetInPlace() bool {
+	if !tail.DetectInPlaceReset || tail.file == nil || tail.offset == 0 || tail.Pipe || tail.gz != nil {
+		return false
+	}
+	start := tail.offset - resetWindow
+	if start < 0 {
+		start = 0
+	}
+	b := make([]byte, tail.offset-start)
+	if _, err := tail.file.ReadAt(b, start); err != nil {
+		// Shrinking is left to the truncation checks.
+		return false
+	}
+	sum := crc32.ChecksumIEEE(b)
+	if !tail.resetSum.set || tail.resetSum.offset != tail.offset {
+		tail.resetSum = resetSum{offset: tail.offset, sum: sum, set: true}
+		return false
+	}
+	if sum == tail.resetSum.sum {
+		return false
+	}
+	tail.resetSum = resetSum{}
+	return true
+}
@@ -0,0 +1,68 @@
+package tail
+
+// jsonScanner tracks where a JSON value read so far stands, enough to tell
+// whether it is complete.
+type jsonScanner struct {
+	depth    int // open objects and arrays
+	inString bool
+	escaped  bool // the previous byte of a string was a backslash
+}
+
+func (s *jsonScanner) scan(b []byte) {
+	for _, c := range b {
+		if s.escaped {
+			s.escaped = false
+			continue
+		}
+		if s.inString {
+			switch c {
+			case '\\':
+				s.escaped = true
+			case '"':
+				s.inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			s.inString = true
+		case '{', '[':
+			s.depth++
+		case '}', ']':
+			s.depth--
+		}
+	}
+}
+
+// complete reports whether a top-level value has ended.
+func (s *jsonScanner) complete() bool {
+	return s.depth <= 0 && !s.inString
+}
+
+// readJSONLine reads a line like readLine for JSONMode, joining lines until
+// the top-level JSON value they hold is complete. The delimiters within the
+// value are kept.
+func (tail *Tail) readJSONLine() ([]byte, int64, bool, error) {
+	var line []byte
+	var s jsonScanner
+	for {
+		tail.lk.Lock()
+		chunk, err := tail.reader.ReadBytes(tail.delimiter())
+		tail.lk.Unlock()
+		line = append(line, chunk...)
+		if err != nil {
+			return line, int64(len(line)), false, err
+		}
+		s.scan(chunk)
+		if s.complete() || (tail.MaxLineSize > 0 && len(line) >= tail.MaxLineSize) {
+			break
+		}
+	}
+
+	read := int64(len(line))
+	tail.rawRead(line)
+	if !tail.KeepDelimiter {
+		line = tail.trimDelimiter(line)
+	}
+	return line, read, false, nil
+}
@@ -0,0 +1,28 @@
+package tail
+
+import "testing"
+
+func TestTail_JSONMode(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("{\"a\": 1}\n{\n  \"b\": [1,\n 2],\n  \"c\": \"}\\\"{\"\n}\n42\n")
+	f.WriteString("{\"d\":\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, JSONMode: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, `{"a": 1}`)
+	eq(t, line.Offset, int64(9))
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "{\n  \"b\": [1,\n 2],\n  \"c\": \"}\\\"{\"\n}")
+	eq(t, line.Offset, int64(43))
+	eq(t, recvLine(t, tailer).Text, "42")
+
+	// The rest of a value is waited for.
+	f.WriteString("true}\n")
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "{\"d\":\ntrue}")
+	eq(t, line.Offset, int64(58))
+}
@@ -0,0 +1,222 @@
+package tail
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gopkg.in/tomb.v1"
+)
+
+// MultiTailer tails several files at once, merging their lines into a single
+// Lines channel. Each Line carries the SourceFile it was read from, as passed
+// to MultiTail or Add.
+type MultiTailer struct {
+	Lines chan *Line
+	Config
+
+	// Errors receives the errors of the files, including those that
+	// stopped tailing one, when SeparateErrors is set, and is nil
+	// otherwise. It is buffered and errors that do not fit are dropped. It
+	// is closed with Lines.
+	Errors <-chan error
+	errs   chan error
+
+	tomb.Tomb // provides: Done, Kill, Dying
+
+	lk       sync.Mutex
+	children map[string]*multiChild
+	started  bool           // all files passed to MultiTail have been added
+	wg       sync.WaitGroup // forwarding goroutines
+}
+
+// multiChild is the Tail of one file of a MultiTailer.
+type multiChild struct {
+	tail    *Tail
+	removed chan struct{} // closed by Remove
+}
+
+// MultiTail begins tailing each of filenames with config, which applies to
+// every file, Location included. If a file fails, a Line with Err set is sent,
+// or its error is sent on Errors with SeparateErrors, and the other files are
+// still tailed. Without Follow, Lines is closed once every file has been
+// read; otherwise tailing continues until Stop. A Watcher, PositionStore or
+// CheckpointPath would be shared by the files and cannot be set.
+func MultiTail(filenames []string, config Config) (*MultiTailer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.CheckpointPath != "" || config.PositionStore != nil {
+		return nil, errors.New("tail: CheckpointPath and PositionStore cannot be used with MultiTail")
+	}
+	if config.Watcher != nil {
+		return nil, errors.New("tail: a Watcher cannot be shared by the files of MultiTail")
+	}
+	if config.Logger == nil {
+		config.Logger = DiscardLogger
+	}
+	m := &MultiTailer{
+		Lines:    make(chan *Line, config.MaxBufferedLines),
+		Config:   config,
+		children: make(map[string]*multiChild),
+	}
+	if config.SeparateErrors {
+		m.errs = make(chan error, errorsBuffer)
+		m.Errors = m.errs
+	}
+	for _, name := range filenames {
+		if err := m.Add(name); err != nil {
+			m.Kill(nil)
+			m.stopChildren()
+			return nil, err
+		}
+	}
+
+	go m.run()
+	m.lk.Lock()
+	m.started = true
+	finished := !config.Follow && len(m.children) == 0
+	m.lk.Unlock()
+	if finished {
+		m.Kill(nil)
+	}
+	return m, nil
+}
+
+// Add starts tailing another file. It returns ErrNotRunning once the
+// MultiTailer has stopped.
+func (m *MultiTailer) Add(filename string) error {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	// Checked under lk so that run stops every file added.
+	select {
+	case <-m.Dying():
+		return ErrNotRunning
+	default:
+	}
+	if _, ok := m.children[filename]; ok {
+		return fmt.Errorf("tail: %s is already tailed", filename)
+	}
+	config := m.Config
+	config.KeepChannelOpen, config.Channel = false, nil
+	t, err := TailFile(filename, config)
+	if err != nil {
+		return err
+	}
+	c := &multiChild{tail: t, removed: make(chan struct{})}
+	m.children[filename] = c
+	m.wg.Add(1)
+	go m.forward(filename, c)
+	return nil
+}
+
+// Remove stops tailing a file and returns the error that stopped it, if
+// any. Lines of it not yet received are discarded.
+func (m *MultiTailer) Remove(filename string) error {
+	m.lk.Lock()
+	c := m.children[filename]
+	delete(m.children, filename)
+	m.lk.Unlock()
+	if c == nil {
+		return fmt.Errorf("tail: %s is not tailed", filename)
+	}
+	close(c.removed)
+	c.tail.Kill(nil)
+	return c.tail.Wait()
+}
+
+// Stop stops tailing all files and waits for Lines to be closed.
+func (m *MultiTailer) Stop() error {
+	m.Kill(nil)
+	return m.Wait()
+}
+
+// run stops the files once the MultiTailer is dying and closes Lines after
+// their last lines have been forwarded.
+func (m *MultiTailer) run() {
+	defer m.Done()
+	<-m.Dying()
+	m.stopChildren()
+	m.wg.Wait()
+	close(m.Lines)
+	if m.errs != nil {
+		close(m.errs)
+	}
+}
+
+// reportError sends err on Errors, if SeparateErrors is set, without
+// blocking. It returns false if it is not.
+func (m *MultiTailer) reportError(err error) bool {
+	if m.errs == nil {
+		return false
+	}
+	select {
+	case m.errs <- err:
+	default:
+	}
+	return true
+}
+
+func (m *MultiTailer) stopChildren() {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	for _, c := range m.children {
+		c.tail.Kill(nil)
+	}
+}
+
+// forward sends the lines of one file on Lines until it is finished or
+// removed.
+func (m *MultiTailer) forward(filename string, c *multiChild) {
+	defer m.wg.Done()
+	defer c.tail.Cleanup()
+
+	lines, errs := c.tail.Lines, c.tail.Errors
+	for lines != nil {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				lines = nil
+				continue
+			}
+			line.SourceFile = filename
+			select {
+			case m.Lines <- line:
+			case <-c.removed:
+			case <-m.Dying():
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			m.reportError(err)
+		}
+	}
+	// Errors is closed right after Lines, following its last errors.
+	if errs != nil {
+		for err := range errs {
+			m.reportError(err)
+		}
+	}
+
+	err := c.tail.Wait()
+	m.lk.Lock()
+	current := m.children[filename] == c
+	if current {
+		delete(m.children, filename)
+	}
+	finished := current && m.started && !m.Follow && len(m.children) == 0
+	m.lk.Unlock()
+
+	if err != nil && current && !m.reportError(err) {
+		select {
+		case m.Lines <- &Line{Time: time.Now(), Err: err, Filename: c.tail.openedName, SourceFile: filename, Tag: m.Name}:
+		case <-m.Dying():
+		}
+	}
+	if finished {
+		m.Kill(nil)
+	}
+}
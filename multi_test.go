@@ -0,0 +1,132 @@
+package tail
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/tenebris-tech/tail/watch"
+)
+
+func TestMultiTail(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.log")
+	b := filepath.Join(dir, "b.log")
+	c := filepath.Join(dir, "c.log")
+	noError(t, os.WriteFile(a, []byte("a1\n"), 0600))
+	noError(t, os.WriteFile(b, []byte("b1\n"), 0600))
+	noError(t, os.WriteFile(c, []byte("c1\n"), 0600))
+
+	m, err := MultiTail([]string{a, b}, Config{Follow: true})
+	noError(t, err)
+
+	recv := func() *Line {
+		t.Helper()
+		line, ok := <-m.Lines
+		if !ok {
+			t.Fatal("Lines closed")
+		}
+		return line
+	}
+	got := map[string]string{}
+	for i := 0; i < 2; i++ {
+		line := recv()
+		got[line.SourceFile] = line.Text
+	}
+	eq(t, got, map[string]string{a: "a1", b: "b1"})
+
+	noError(t, m.Add(c))
+	line := recv()
+	eq(t, line.SourceFile, c)
+	eq(t, line.Text, "c1")
+
+	noError(t, m.Remove(a))
+	if err := m.Remove(a); err == nil {
+		t.Fatal("expected an error removing a file twice")
+	}
+	f, err := os.OpenFile(a, os.O_APPEND|os.O_WRONLY, 0)
+	noError(t, err)
+	f.WriteString("a2\n")
+	f.Close()
+	f, err = os.OpenFile(b, os.O_APPEND|os.O_WRONLY, 0)
+	noError(t, err)
+	f.WriteString("b2\n")
+	f.Close()
+	line = recv()
+	eq(t, line.SourceFile, b)
+	eq(t, line.Text, "b2")
+
+	noError(t, m.Stop())
+	if _, ok := <-m.Lines; ok {
+		t.Fatal("expected Lines to be closed")
+	}
+	eq(t, m.Add(a), ErrNotRunning)
+}
+
+func TestMultiTail_WithoutFollow(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.log")
+	b := filepath.Join(dir, "b.log")
+	noError(t, os.WriteFile(a, []byte("a1\na2\n"), 0600))
+	noError(t, os.WriteFile(b, []byte("b1\n"), 0600))
+
+	// A failing file does not stop the others.
+	m, err := MultiTail([]string{a, b, dir}, Config{})
+	noError(t, err)
+
+	var got []string
+	var errs int
+	for line := range m.Lines {
+		if line.Err != nil {
+			errs++
+			if !errors.Is(line.Err, ErrNotRegularFile) || line.SourceFile != dir {
+				t.Fatalf("unexpected error line %+v", line)
+			}
+			continue
+		}
+		got = append(got, line.Text)
+	}
+	noError(t, m.Wait())
+	sort.Strings(got)
+	eq(t, got, []string{"a1", "a2", "b1"})
+	eq(t, errs, 1)
+}
+
+func TestMultiTail_SeparateErrors(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.log")
+	noError(t, os.WriteFile(a, []byte("a1\n"), 0600))
+
+	m, err := MultiTail([]string{a, dir}, Config{SeparateErrors: true})
+	noError(t, err)
+
+	var got []string
+	for line := range m.Lines {
+		noError(t, line.Err)
+		got = append(got, line.Text)
+	}
+	noError(t, m.Wait())
+	eq(t, got, []string{"a1"})
+
+	var errs []error
+	for err := range m.Errors {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrNotRegularFile) {
+		t.Fatalf("expected one ErrNotRegularFile on Errors, got %v", errs)
+	}
+}
+
+func TestMultiTail_SharedState(t *testing.T) {
+	for _, config := range []Config{
+		{Watcher: watch.NewPollingFileWatcher("x")},
+		{PositionStore: &fakePositionStore{}},
+		{CheckpointPath: "x"},
+	} {
+		if _, err := MultiTail(nil, config); err == nil {
+			t.Errorf("MultiTail accepted %+v", config)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package tail
+
+import "fmt"
+
+// namedLogger prefixes the messages of a Tail with Config.Name.
+type namedLogger struct {
+	l      logger
+	prefix string
+}
+
+// withName returns l prefixing its messages with name, or l itself if name
+// is empty.
+func withName(l logger, name string) logger {
+	if name == "" {
+		return l
+	}
+	nl := namedLogger{l: l, prefix: "[" + name + "] "}
+	if el, ok := l.(eventLogger); ok {
+		return namedEventLogger{namedLogger: nl, el: el, name: name}
+	}
+	return nl
+}
+
+func (n namedLogger) Print(v ...interface{}) { n.l.Print(n.prefix + fmt.Sprint(v...)) }
+func (n namedLogger) Printf(format string, v ...interface{}) {
+	n.l.Printf(n.prefix+format, v...)
+}
+func (n namedLogger) Println(v ...interface{}) { n.l.Print(n.prefix + fmt.Sprintln(v...)) }
+
+func (n namedLogger) Fatal(v ...interface{}) { n.l.Fatal(n.prefix + fmt.Sprint(v...)) }
+func (n namedLogger) Fatalf(format string, v ...interface{}) {
+	n.l.Fatalf(n.prefix+format, v...)
+}
+func (n namedLogger) Fatalln(v ...interface{}) { n.l.Fatal(n.prefix + fmt.Sprintln(v...)) }
+
+func (n namedLogger) Panic(v ...interface{}) { n.l.Panic(n.prefix + fmt.Sprint(v...)) }
+func (n namedLogger) Panicf(format string, v ...interface{}) {
+	n.l.Panicf(n.prefix+format, v...)
+}
+func (n namedLogger) Panicln(v ...interface{}) { n.l.Panic(n.prefix + fmt.Sprintln(v...)) }
+
+// namedEventLogger adds the name as an attribute to events instead.
+type namedEventLogger struct {
+	namedLogger
+	el   eventLogger
+	name string
+}
+
+func (n namedEventLogger) LogEvent(err error, msg string, args ...any) {
+	n.el.LogEvent(err, msg, append([]any{"name", n.name}, args...)...)
+}
@@ -0,0 +1,340 @@
+package tail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/tenebris-tech/tail/watch"
+
+	"gopkg.in/tomb.v1"
+)
+
+// TailNewest begins tailing whichever file matching the glob pattern has
+// the most recent modification time. The pattern is re-evaluated every
+// watch.POLL_DURATION and, when a newer file appears, the current file is
+// drained to EOF before switching over. Each Line carries the SourceFile
+// it was read from.
+//
+// With NumericRotation, the file whose name has the highest number is
+// tailed instead, regardless of modification times.
+//
+// Location and LastNLines are only honored for the first file tailed, while
+// MaxBytes and MaxRuntime bound all the files together. Seek, Reload, Pause
+// and Resume apply to the file being tailed, and Position, Tell, Lag, AtEOF
+// and WatcherKind report on it. Stats and Dropped add up all the files.
+func TailNewest(pattern string, config Config) (*Tail, error) {
+	return tailMatching(pattern, config, false)
+}
+
+// TailGlob is like TailNewest, but tails the file matching the glob pattern
+// whose name sorts last, whatever the modification times. It suits files
+// named by date, such as app-2024-01-02.log, and switches over as soon as a
+// file with a later name appears.
+func TailGlob(pattern string, config Config) (*Tail, error) {
+	return tailMatching(pattern, config, true)
+}
+
+// tailMatching starts a TailNewest or, with byName, a TailGlob.
+func tailMatching(pattern string, config Config, byName bool) (*Tail, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	t, err := newTail(pattern, config)
+	if err != nil {
+		return nil, err
+	}
+	t.byName = byName
+
+	if t.MaxRuntime > 0 {
+		go t.stopAfter(t.MaxRuntime)
+	}
+	go t.tailNewestSync()
+
+	return t, nil
+}
+
+// newestMatch returns the regular file matching the pattern with the most
+// recent modification time, or "" if nothing matches. Ties are broken by
+// name. With NumericRotation, it is the one with the highest number
+// instead, and for TailGlob the one whose name sorts last.
+func (tail *Tail) newestMatch() (string, error) {
+	matches, err := filepath.Glob(tail.Filename)
+	if err != nil {
+		return "", err
+	}
+	if tail.NumericRotation {
+		return highestNumbered(matches), nil
+	}
+	if tail.byName {
+		return lastByName(matches), nil
+	}
+
+	var newest string
+	var newestTime time.Time
+	for _, name := range matches {
+		fi, err := os.Stat(name)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		modTime := fi.ModTime()
+		if newest == "" || modTime.After(newestTime) || (modTime.Equal(newestTime) && name > newest) {
+			newest, newestTime = name, modTime
+		}
+	}
+	return newest, nil
+}
+
+// lastByName returns the regular file of names whose name sorts last, or ""
+// if there is none.
+func lastByName(names []string) string {
+	var last string
+	for _, name := range names {
+		if name <= last {
+			continue
+		}
+		if fi, err := os.Stat(name); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		last = name
+	}
+	return last
+}
+
+// highestNumbered returns the regular file of names whose name has the
+// highest number, or "" if none has one. The number is the last run of
+// digits in the base name, such as the 1 of "1.log" or "app.log.1". Ties
+// are broken by name.
+func highestNumbered(names []string) string {
+	var highest string
+	var highestNum uint64
+	for _, name := range names {
+		n, ok := nameNumber(filepath.Base(name))
+		if !ok || (highest != "" && (n < highestNum || (n == highestNum && name < highest))) {
+			continue
+		}
+		if fi, err := os.Stat(name); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		highest, highestNum = name, n
+	}
+	return highest
+}
+
+// nameNumber returns the last run of digits in name as a number.
+func nameNumber(name string) (uint64, bool) {
+	end := len(name)
+	for end > 0 && (name[end-1] < '0' || name[end-1] > '9') {
+		end--
+	}
+	start := end
+	for start > 0 && name[start-1] >= '0' && name[start-1] <= '9' {
+		start--
+	}
+	n, err := strconv.ParseUint(name[start:end], 10, 64)
+	return n, err == nil
+}
+
+func (tail *Tail) tailNewestSync() {
+	defer tail.Done()
+	if !tail.KeepChannelOpen {
+		defer close(tail.Lines)
+	}
+
+	current, err := tail.waitForNewest()
+	if err != nil {
+		if err != tomb.ErrDying {
+			tail.Kill(err)
+		}
+		return
+	}
+
+	var id string
+	for current != "" {
+		if tail.NumericRotation {
+			// Switching to a higher-numbered file is a reopen.
+			oldID := id
+			id, _ = FileIdentifierForPath(current)
+			if oldID != "" && tail.OnReopen != nil {
+				tail.OnReopen(oldID, id)
+			}
+		}
+		child, err := TailFile(current, tail.newestConfig())
+		if err != nil {
+			tail.Kill(err)
+			return
+		}
+		tail.Location, tail.LastNLines = nil, 0
+
+		tail.stateLk.Lock()
+		if tail.newest != nil {
+			tail.addCounts(tail.newest)
+		}
+		tail.newest = child
+		if tail.resume != nil {
+			child.Pause()
+		}
+		tail.stateLk.Unlock()
+
+		current, err = tail.drainNewest(child, current)
+		child.Cleanup()
+		if err != nil {
+			if err != tomb.ErrDying {
+				tail.Kill(err)
+			}
+			return
+		}
+		// child has stopped, so its counts are final.
+		tail.bytesRead += child.bytesRead
+		if reason := child.StopReason(); reason != NotStopped {
+			tail.stateLk.Lock()
+			tail.stopReason = reason
+			tail.stateLk.Unlock()
+			return
+		}
+	}
+}
+
+// newestTail returns the tail of the file being read by a TailNewest, or nil
+// for other tails and before a file has been opened.
+func (tail *Tail) newestTail() *Tail {
+	tail.stateLk.Lock()
+	defer tail.stateLk.Unlock()
+	return tail.newest
+}
+
+// addCounts adds the counters of child, which has stopped, to those of a
+// TailNewest. It must be called with stateLk held.
+func (tail *Tail) addCounts(child *Tail) {
+	s := child.Stats()
+	tail.stats.linesRead.Add(s.LinesRead)
+	tail.stats.bytesRead.Add(s.BytesRead)
+	tail.stats.reopens.Add(s.Reopens)
+	tail.stats.truncations.Add(s.Truncations)
+	tail.stats.errors.Add(s.Errors)
+	tail.dropped.Add(s.Dropped)
+}
+
+// newestConfig returns the config of the Tail of each file. Its lines are
+// forwarded until its Lines is closed, and its messages are prefixed with
+// Name by the logger it shares.
+func (tail *Tail) newestConfig() Config {
+	config := tail.Config
+	config.KeepChannelOpen, config.Channel = false, nil
+	// newTail has already turned these into PositionStore and Location.
+	config.CheckpointPath, config.SeekEnd = "", false
+	config.Name = ""
+	// MaxRuntime runs in the parent, and each file gets what is left of
+	// MaxBytes. The checkpoint settings need not carry over: a file is
+	// checkpointed as it is left.
+	config.MaxRuntime = 0
+	if tail.MaxBytes > 0 {
+		config.MaxBytes = tail.MaxBytes - tail.bytesRead
+	}
+	return config
+}
+
+// waitForNewest blocks until at least one file matches the pattern.
+func (tail *Tail) waitForNewest() (string, error) {
+	for {
+		name, err := tail.newestMatch()
+		if err != nil {
+			return "", err
+		}
+		if name != "" {
+			return name, nil
+		}
+		select {
+		case <-tail.clock.After(watch.POLL_DURATION):
+		case req := <-tail.seeks:
+			req.done <- fmt.Errorf("tail: no file matches %s", tail.Filename)
+		case req := <-tail.reloads:
+			tail.serveReload(req)
+		case <-tail.Dying():
+			return "", tomb.ErrDying
+		}
+	}
+}
+
+// drainNewest forwards lines from child until it is closed. When a newer file
+// matches the pattern, child is stopped at EOF and the name of the newer file
+// is returned. An empty name means tailing is finished.
+func (tail *Tail) drainNewest(child *Tail, current string) (string, error) {
+	poll, stopPoll := tail.clock.NewTimer(watch.POLL_DURATION)
+	defer func() { stopPoll() }()
+
+	dying := tail.Dying()
+	next := ""
+
+	// finishOnDying handles the tomb dying. On StopAtEOF the current file is
+	// finished before stopping, otherwise tailing stops immediately.
+	finishOnDying := func() bool {
+		if tail.Err() != errStopAtEOF {
+			stopAndDrain(child)
+			return false
+		}
+		dying, next = nil, ""
+		go child.StopAtEOF()
+		return true
+	}
+
+	for {
+		select {
+		case line, ok := <-child.Lines:
+			if !ok {
+				return next, child.Wait()
+			}
+			line.SourceFile = current
+			if line.Tag == "" {
+				line.Tag = tail.Name
+			}
+			select {
+			case tail.Lines <- line:
+			case <-dying:
+				if !finishOnDying() {
+					return "", tomb.ErrDying
+				}
+				tail.Lines <- line
+			}
+		case err := <-child.Errors:
+			tail.reportError(err)
+		case req := <-tail.seeks:
+			tail.drainLines()
+			req.done <- child.Seek(req.pos)
+		case req := <-tail.reloads:
+			if err := child.Reload(req.config); err != nil {
+				req.done <- err
+				continue
+			}
+			tail.serveReload(req)
+		case <-poll:
+			poll, stopPoll = tail.clock.NewTimer(watch.POLL_DURATION)
+			if next != "" || dying == nil {
+				continue
+			}
+			name, err := tail.newestMatch()
+			if err != nil {
+				stopAndDrain(child)
+				return "", err
+			}
+			if name != "" && name != current {
+				next = name
+				go child.StopAtEOF()
+			}
+		case <-dying:
+			if !finishOnDying() {
+				return "", tomb.ErrDying
+			}
+		}
+	}
+}
+
+// stopAndDrain stops child, discarding any lines it is still trying to send.
+func stopAndDrain(child *Tail) {
+	child.Kill(nil)
+	for range child.Lines {
+	}
+	_ = child.Wait()
+}
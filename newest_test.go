@@ -0,0 +1,292 @@
+package tail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tenebris-tech/tail/watch"
+)
+
+func TestTailNewest(t *testing.T) {
+	testDir := t.TempDir()
+	older := filepath.Join(testDir, "app-20240101.log")
+	newer := filepath.Join(testDir, "app-20240102.log")
+
+	f, err := os.Create(older)
+	noError(t, err)
+	defer f.Close()
+	f.WriteString("one\n")
+
+	tailer, err := TailNewest(filepath.Join(testDir, "app-*.log"), Config{Follow: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Stop()
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "one")
+	eq(t, line.SourceFile, older)
+
+	// The newer file appears while the older one is still being written.
+	f.WriteString("two\n")
+	g, err := os.Create(newer)
+	noError(t, err)
+	defer g.Close()
+	g.WriteString("three\n")
+	future := time.Now().Add(time.Hour)
+	noError(t, os.Chtimes(newer, future, future))
+
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "two")
+	eq(t, line.SourceFile, older)
+
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "three")
+	eq(t, line.SourceFile, newer)
+}
+
+func recvLine(t *testing.T, tailer *Tail) *Line {
+	t.Helper()
+	select {
+	case line, ok := <-tailer.Lines:
+		if !ok {
+			t.Fatalf("Lines closed unexpectedly: %v", tailer.Err())
+		}
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for line")
+	}
+	return nil
+}
+
+func TestTailNewest_WaitsForMatch(t *testing.T) {
+	testDir := t.TempDir()
+	first := filepath.Join(testDir, "app-2024-01-01.log")
+	second := filepath.Join(testDir, "app-2024-01-02.log")
+
+	tailer, err := TailNewest(filepath.Join(testDir, "app-*.log"), Config{Follow: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Stop()
+
+	// Nothing matches yet, so tailing blocks until a file appears.
+	select {
+	case line := <-tailer.Lines:
+		t.Fatalf("unexpected line %v", line)
+	case <-time.After(2 * watch.POLL_DURATION):
+	}
+
+	f, err := os.Create(first)
+	noError(t, err)
+	defer f.Close()
+	f.WriteString("one\n")
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "one")
+	firstID := line.FileIdentifier
+
+	g, err := os.Create(second)
+	noError(t, err)
+	defer g.Close()
+	g.WriteString("two\n")
+	future := time.Now().Add(time.Hour)
+	noError(t, os.Chtimes(second, future, future))
+
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "two")
+	eq(t, line.SourceFile, second)
+	if line.FileIdentifier == firstID {
+		t.Fatalf("FileIdentifier did not change when switching files: %q", firstID)
+	}
+}
+
+func TestTailNewest_NumericRotation(t *testing.T) {
+	testDir := t.TempDir()
+	first := filepath.Join(testDir, "0.log")
+	second := filepath.Join(testDir, "1.log")
+
+	f, err := os.Create(first)
+	noError(t, err)
+	defer f.Close()
+	f.WriteString("one\n")
+	firstID, err := FileIdentifier(f)
+	noError(t, err)
+
+	reopens := make(chan [2]string, 1)
+	onReopen := func(oldID, newID string) { reopens <- [2]string{oldID, newID} }
+	tailer, err := TailNewest(filepath.Join(testDir, "*.log"), Config{Follow: true, NumericRotation: true, OnReopen: onReopen, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Stop()
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "one")
+	eq(t, line.Filename, first)
+
+	// The container restarts; 0.log may still be written to last.
+	g, err := os.Create(second)
+	noError(t, err)
+	defer g.Close()
+	g.WriteString("two\n")
+	secondID, err := FileIdentifier(g)
+	noError(t, err)
+	future := time.Now().Add(time.Hour)
+	noError(t, os.Chtimes(first, future, future))
+
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "two")
+	eq(t, line.Filename, second)
+	eq(t, <-reopens, [2]string{firstID, secondID})
+}
+
+func TestNameNumber(t *testing.T) {
+	for name, want := range map[string]uint64{
+		"0.log":      0,
+		"12.log":     12,
+		"app.log.3":  3,
+		"app-7.log":  7,
+		"v2-app.log": 2,
+	} {
+		n, ok := nameNumber(name)
+		if !ok || n != want {
+			t.Errorf("nameNumber(%q) = %d, %v, want %d", name, n, ok, want)
+		}
+	}
+	if _, ok := nameNumber("app.log"); ok {
+		t.Error("nameNumber(\"app.log\") found a number")
+	}
+}
+
+func TestTailNewest_Controls(t *testing.T) {
+	testDir := t.TempDir()
+	name := filepath.Join(testDir, "app-1.log")
+	f, err := os.Create(name)
+	noError(t, err)
+	defer f.Close()
+	f.WriteString("one\ntwo\n")
+
+	tailer, err := TailNewest(filepath.Join(testDir, "app-*.log"), Config{Follow: true, MaxBufferedLines: 4, Name: "app", Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Stop()
+	eq(t, cap(tailer.Lines), 4)
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "one")
+	eq(t, line.Tag, "app")
+	eq(t, recvLine(t, tailer).Text, "two")
+
+	noError(t, tailer.Seek(SeekInfo{Offset: 4}))
+	eq(t, recvLine(t, tailer).Text, "two")
+	noError(t, tailer.Reload(Config{Follow: true, MaxBufferedLines: 4, PollInterval: time.Second}))
+
+	tailer.Pause()
+	f.WriteString("three\n")
+	select {
+	case line := <-tailer.Lines:
+		t.Fatalf("unexpected line %q while paused", line.Text)
+	case <-time.After(2 * watch.POLL_DURATION):
+	}
+	tailer.Resume()
+	eq(t, recvLine(t, tailer).Text, "three")
+}
+
+func TestTailNewest_Accessors(t *testing.T) {
+	testDir := t.TempDir()
+	older := filepath.Join(testDir, "app-1.log")
+	newer := filepath.Join(testDir, "app-2.log")
+	noError(t, os.WriteFile(older, []byte("one\n"), 0600))
+
+	tailer, err := TailNewest(filepath.Join(testDir, "app-*.log"), Config{Follow: true, Poll: true, MaxBytes: 12, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Stop()
+	eq(t, recvLine(t, tailer).Text, "one")
+	eq(t, tailer.WatcherKind(), WatcherPolling)
+	pos, err := tailer.Position()
+	noError(t, err)
+	eq(t, pos.Offset, int64(4))
+
+	// MaxBytes counts the bytes of both files.
+	noError(t, os.WriteFile(newer, []byte("two\nthree\n"), 0600))
+	future := time.Now().Add(time.Hour)
+	noError(t, os.Chtimes(newer, future, future))
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "two")
+	eq(t, line.SourceFile, newer)
+	eq(t, recvLine(t, tailer).Err, ErrByteQuotaExceeded)
+	for range tailer.Lines {
+	}
+	noError(t, tailer.Wait())
+	eq(t, tailer.StopReason(), ByteLimit)
+
+	pos, err = tailer.Position()
+	noError(t, err)
+	eq(t, pos.Offset, int64(4))
+	s := tailer.Stats()
+	eq(t, s.LinesRead, uint64(2))
+	eq(t, s.BytesRead, uint64(8))
+}
+
+func TestTailNewest_MaxRuntime(t *testing.T) {
+	testDir := t.TempDir()
+	noError(t, os.WriteFile(filepath.Join(testDir, "app-1.log"), []byte("one\n"), 0600))
+
+	clock := newFakeClock()
+	tailer, err := TailNewest(filepath.Join(testDir, "app-*.log"), Config{Follow: true, MaxRuntime: time.Hour, clock: clock, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Stop()
+	eq(t, recvLine(t, tailer).Text, "one")
+
+	// The runtime of the parent, and the poll for newer files.
+	clock.BlockUntil(2)
+	clock.Advance(time.Hour)
+	for range tailer.Lines {
+	}
+	noError(t, tailer.Wait())
+	eq(t, tailer.StopReason(), TimeLimit)
+}
+
+func TestTailGlob(t *testing.T) {
+	testDir := t.TempDir()
+	first := filepath.Join(testDir, "app-2024-01-01.log")
+	second := filepath.Join(testDir, "app-2024-01-02.log")
+
+	// The earlier file by name is the most recently modified.
+	f, err := os.Create(first)
+	noError(t, err)
+	defer f.Close()
+	f.WriteString("one\n")
+	future := time.Now().Add(time.Hour)
+	noError(t, os.Chtimes(first, future, future))
+
+	tailer, err := TailGlob(filepath.Join(testDir, "app-*.log"), Config{Follow: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Stop()
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "one")
+	eq(t, line.SourceFile, first)
+	oldID := line.FileIdentifier
+
+	f.WriteString("two\n")
+	g, err := os.Create(second)
+	noError(t, err)
+	defer g.Close()
+	g.WriteString("three\n")
+
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "two")
+	eq(t, line.SourceFile, first)
+
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "three")
+	eq(t, line.SourceFile, second)
+	if line.FileIdentifier == oldID {
+		t.Fatalf("FileIdentifier %q did not change with the file", oldID)
+	}
+}
+
+func TestLastByName(t *testing.T) {
+	testDir := t.TempDir()
+	a := filepath.Join(testDir, "app-1.log")
+	b := filepath.Join(testDir, "app-2.log")
+	noError(t, os.WriteFile(a, nil, 0600))
+	noError(t, os.Mkdir(b, 0700))
+	eq(t, lastByName([]string{a, b}), a)
+	eq(t, lastByName(nil), "")
+}
@@ -0,0 +1,74 @@
+package tail
+
+import "time"
+
+// Option sets a field of the Config used by TailFileWith. Options are
+// applied in order, so later ones override earlier ones; a slice of them
+// can be shared as defaults.
+type Option func(*Config)
+
+// TailFileWith is like TailFile with a Config built from opts.
+func TailFileWith(filename string, opts ...Option) (*Tail, error) {
+	var config Config
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return TailFile(filename, config)
+}
+
+// WithFollow sets Follow.
+func WithFollow() Option {
+	return func(c *Config) { c.Follow = true }
+}
+
+// WithReopen sets ReOpen and, as ReOpen requires it, Follow.
+func WithReopen() Option {
+	return func(c *Config) {
+		c.ReOpen = true
+		c.Follow = true
+	}
+}
+
+// WithPoll sets Poll.
+func WithPoll() Option {
+	return func(c *Config) { c.Poll = true }
+}
+
+// WithPollInterval sets Poll and PollInterval.
+func WithPollInterval(d time.Duration) Option {
+	return func(c *Config) {
+		c.Poll = true
+		c.PollInterval = d
+	}
+}
+
+// WithMustExist sets MustExist.
+func WithMustExist() Option {
+	return func(c *Config) { c.MustExist = true }
+}
+
+// WithPipe sets Pipe.
+func WithPipe() Option {
+	return func(c *Config) { c.Pipe = true }
+}
+
+// WithLocation sets Location.
+func WithLocation(pos SeekInfo) Option {
+	return func(c *Config) { c.Location = &pos }
+}
+
+// WithMaxLineSize sets MaxLineSize.
+func WithMaxLineSize(n int) Option {
+	return func(c *Config) { c.MaxLineSize = n }
+}
+
+// WithLogger sets Logger.
+func WithLogger(l logger) Option {
+	return func(c *Config) { c.Logger = l }
+}
+
+// WithConfig replaces the Config built so far with config, for fields that
+// have no Option.
+func WithConfig(config Config) Option {
+	return func(c *Config) { *c = config }
+}
@@ -0,0 +1,36 @@
+package tail
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func TestTailFileWith(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\ntwo\n")
+
+	defaults := []Option{WithReopen(), WithLogger(DiscardingLogger)}
+	tailer, err := TailFileWith(testFile, append(defaults, WithLocation(SeekInfo{Offset: 4, Whence: io.SeekStart}))...)
+	noError(t, err)
+	defer cleanTailer(tailer)
+
+	eq(t, tailer.Follow, true)
+	eq(t, tailer.ReOpen, true)
+	eq(t, recvLine(t, tailer).Text, "two")
+
+	noError(t, os.Rename(testFile, testFile+".1"))
+	noError(t, os.WriteFile(testFile, []byte("three\n"), 0600))
+	eq(t, recvLine(t, tailer).Text, "three")
+}
+
+func TestTailFileWith_Order(t *testing.T) {
+	var config Config
+	for _, opt := range []Option{WithPoll(), WithConfig(Config{MaxLineSize: 10}), WithFollow()} {
+		opt(&config)
+	}
+	eq(t, config.Poll, false)
+	eq(t, config.MaxLineSize, 10)
+	eq(t, config.Follow, true)
+}
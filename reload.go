@@ -0,0 +1,68 @@
+package tail
+
+import (
+	"fmt"
+
+	"github.com/tenebris-tech/tail/watch"
+)
+
+// reloadRequest asks the tailing goroutine to apply a new Config.
+type reloadRequest struct {
+	config Config
+	done   chan error
+}
+
+// Reload changes the options of a running Tail without losing its position,
+// e.g. on SIGHUP. Only these fields of config are applied:
+//
+//   - PollInterval, from the next poll on, unless MinPollInterval is set
+//   - RateLimit and RateLimiter, from the next line on
+//   - LineFilter, from the next line on
+//   - ReadBufferSize, from the next time the file is opened
+//
+// Other fields are ignored, except that Reload returns an error if config
+// is invalid or changes Poll or MaxBufferedLines, which cannot be changed
+// while tailing. It returns ErrNotRunning if tailing has stopped.
+func (tail *Tail) Reload(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if config.Poll != tail.Poll {
+		return fmt.Errorf("tail: cannot change Poll of a running tail")
+	}
+	if config.MaxBufferedLines != tail.MaxBufferedLines {
+		return fmt.Errorf("tail: cannot change MaxBufferedLines of a running tail")
+	}
+	req := reloadRequest{config: config, done: make(chan error, 1)}
+	select {
+	case tail.reloads <- req:
+	case <-tail.Dying():
+		return ErrNotRunning
+	case <-tail.Dead():
+		return ErrNotRunning
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-tail.Dead():
+		return ErrNotRunning
+	}
+}
+
+// serveReload handles a Reload request on the tailing goroutine, which is
+// the only one reading the fields it changes.
+func (tail *Tail) serveReload(req reloadRequest) {
+	tail.PollInterval = req.config.PollInterval
+	tail.RateLimit = req.config.RateLimit
+	tail.RateLimiter = req.config.RateLimiter
+	tail.LineFilter = req.config.LineFilter
+	tail.ReadBufferSize = req.config.ReadBufferSize
+
+	tail.stateLk.Lock()
+	fw, ok := tail.watcher.(*watch.PollingFileWatcher)
+	tail.stateLk.Unlock()
+	if ok {
+		fw.SetInterval(tail.pollInterval())
+	}
+	req.done <- nil
+}
@@ -0,0 +1,36 @@
+package tail
+
+import "bytes"
+
+// holdRepeat holds l back for SuppressRepeats, counting the identical
+// lines that follow it, and sends the line held before if l differs.
+func (tail *Tail) holdRepeat(l *Line) {
+	if h := tail.held; h != nil && !l.Reset && !l.Partial && h.Truncated == l.Truncated && bytes.Equal(h.Bytes, l.Bytes) {
+		h.RepeatCount++
+		h.Offset, h.Num, h.Time = l.Offset, l.Num, l.Time
+		return
+	}
+	tail.flushRepeat()
+	if tail.seeked {
+		// l was read before the seek.
+		return
+	}
+	l.RepeatCount = 1
+	tail.held = l
+}
+
+// flushRepeat sends the line held back by SuppressRepeats, if any, and
+// records its position.
+func (tail *Tail) flushRepeat() {
+	l := tail.held
+	if l == nil {
+		return
+	}
+	tail.held = nil
+	if !tail.pace() || !tail.send(l) {
+		return
+	}
+	if !tail.seeked {
+		tail.recordPosition(l.Offset)
+	}
+}
@@ -0,0 +1,62 @@
+package tail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTail_SuppressRepeats(t *testing.T) {
+	tailer, err := TailReader(strings.NewReader("a\na\na\nb\nc\nc"), Config{SuppressRepeats: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	want := []struct {
+		text   string
+		count  int
+		offset int64
+		num    int
+	}{
+		{"a", 3, 6, 3},
+		{"b", 1, 8, 4},
+		{"c", 1, 10, 5},
+		{"c", 1, 10, 6}, // without a delimiter
+	}
+	for _, w := range want {
+		line := recvLine(t, tailer)
+		eq(t, line.Text, w.text)
+		eq(t, line.RepeatCount, w.count)
+		eq(t, line.Offset, w.offset)
+		eq(t, line.Num, w.num)
+	}
+	if line, ok := <-tailer.Lines; ok {
+		t.Fatalf("unexpected line %+v", line)
+	}
+}
+
+func TestTail_SuppressRepeatsFollow(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("x\nx\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, SuppressRepeats: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+
+	// The end of the file flushes the count.
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "x")
+	eq(t, line.RepeatCount, 2)
+	eq(t, line.Offset, int64(4))
+
+	f.WriteString("x\ny\ny\ny\nz\n")
+	for _, w := range []struct {
+		text   string
+		count  int
+		offset int64
+	}{{"x", 1, 6}, {"y", 3, 12}, {"z", 1, 14}} {
+		line := recvLine(t, tailer)
+		eq(t, line.Text, w.text)
+		eq(t, line.RepeatCount, w.count)
+		eq(t, line.Offset, w.offset)
+	}
+}
@@ -0,0 +1,84 @@
+package tail
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// rotatedFiles returns the rotations of the file that exist, <name>.1 or
+// <name>.1.gz and so on until one is missing, oldest first.
+func (tail *Tail) rotatedFiles() []string {
+	var names []string
+	for n := 1; ; n++ {
+		name := tail.Filename + "." + strconv.Itoa(n)
+		if _, err := os.Stat(name); err != nil {
+			name += ".gz"
+			if _, err := os.Stat(name); err != nil {
+				break
+			}
+		}
+		names = append(names, name)
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return names
+}
+
+// catchUpRotated sends the lines of the rotations of the file, oldest first,
+// when CatchUpRotated is set and the file is read from its start. Each
+// rotation is read like a new file, with offsets and Num starting over and
+// Filename set to its path. It returns false if the tail is dying.
+func (tail *Tail) catchUpRotated() bool {
+	if !tail.CatchUpRotated || tail.Location != nil || tail.SeekTime != nil || tail.file == nil || tail.Pipe || tail.gzipReplay() {
+		return true
+	}
+	names := tail.rotatedFiles()
+	if len(names) == 0 {
+		return true
+	}
+	openedName, fileIdentifier := tail.openedName, tail.fileIdentifier
+	defer func() {
+		tail.openedName, tail.fileIdentifier = openedName, fileIdentifier
+		tail.offset = 0
+		tail.resetNum()
+	}()
+
+	for _, name := range names {
+		if !tail.catchUpFile(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// catchUpFile sends the lines of one rotation of the file.
+func (tail *Tail) catchUpFile(name string) bool {
+	f, err := os.Open(name)
+	if err != nil {
+		tail.logEvent("read_error", err, "Failed to open %s: %s", name, err)
+		return true
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := tail.newGzipReader(f, name)
+		if err != nil {
+			tail.logEvent("read_error", err, "Failed to read %s: %s", name, err)
+			return true
+		}
+		r = gz
+	}
+	tail.openedName = name
+	fileIdentifier, _ := FileIdentifier(f)
+	tail.fileIdentifier = tail.withGeneration(fileIdentifier, f)
+	tail.offset = 0
+	tail.resetNum()
+	tail.skipLeft = tail.SkipLines
+	span := tail.startReplay(name)
+	defer span.End()
+	return tail.sendRemaining(tail.newReader(r), name)
+}
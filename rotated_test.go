@@ -0,0 +1,67 @@
+package tail
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTail_CatchUpRotated(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "app.log")
+	noError(t, os.WriteFile(testFile, []byte("live\n"), 0600))
+	noError(t, os.WriteFile(testFile+".1", []byte("one a\none b\n"), 0600))
+	f, err := os.Create(testFile + ".2.gz")
+	noError(t, err)
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte("two\n"))
+	noError(t, gz.Close())
+	noError(t, f.Close())
+	// Not reached, as there is no .3.
+	noError(t, os.WriteFile(testFile+".4", []byte("four\n"), 0600))
+
+	tailer, err := TailFile(testFile, Config{CatchUpRotated: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	type got struct {
+		text, file string
+		offset     int64
+		num        int
+	}
+	var lines []got
+	for line := range tailer.Lines {
+		noError(t, line.Err)
+		lines = append(lines, got{line.Text, filepath.Base(line.Filename), line.Offset, line.Num})
+	}
+	noError(t, tailer.Wait())
+	eq(t, lines, []got{
+		{"two", "app.log.2.gz", 4, 1},
+		{"one a", "app.log.1", 6, 1},
+		{"one b", "app.log.1", 12, 2},
+		{"live", "app.log", 5, 1},
+	})
+}
+
+func TestTail_CatchUpRotatedMaxBytes(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "app.log")
+	noError(t, os.WriteFile(testFile, []byte("live\n"), 0600))
+	noError(t, os.WriteFile(testFile+".1", []byte("one a\none b\n"), 0600))
+
+	tailer, err := TailFile(testFile, Config{CatchUpRotated: true, MaxBytes: 6, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	var got []string
+	for line := range tailer.Lines {
+		if line.Err != nil {
+			eq(t, line.Err, ErrByteQuotaExceeded)
+			got = append(got, "<quota>")
+			continue
+		}
+		got = append(got, line.Text)
+	}
+	noError(t, tailer.Wait())
+	eq(t, got, []string{"one a", "<quota>"})
+	eq(t, tailer.StopReason(), ByteLimit)
+}
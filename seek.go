@@ -0,0 +1,119 @@
+package tail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotRunning is returned by Seek once tailing has stopped.
+var ErrNotRunning = errors.New("tail: not running")
+
+// checkWhence rejects the whence values that SeekInfo does not support.
+// io.SeekCurrent is not, as the file position runs ahead of the lines read.
+func checkWhence(whence int) error {
+	switch whence {
+	case io.SeekStart, io.SeekEnd:
+		return nil
+	}
+	return fmt.Errorf("tail: unsupported whence %d, must be io.SeekStart or io.SeekEnd", whence)
+}
+
+// resolveSeek returns pos relative to the start of the file. An offset
+// before the start of the file is clamped to it, so that "the last n
+// bytes" of a shorter file means all of it.
+func (tail *Tail) resolveSeek(pos SeekInfo) (SeekInfo, error) {
+	if err := checkWhence(pos.Whence); err != nil {
+		return pos, err
+	}
+	if pos.Whence == io.SeekEnd {
+		seeker, err := tail.seeker()
+		if err != nil {
+			return pos, err
+		}
+		size, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return pos, fmt.Errorf("seek error on %s: %s", tail.Filename, err)
+		}
+		pos.Offset += size
+		pos.Whence = io.SeekStart
+	}
+	if pos.Offset < 0 {
+		pos.Offset = 0
+	}
+	return pos, nil
+}
+
+// seekRequest asks the tailing goroutine to reposition its read cursor.
+type seekRequest struct {
+	pos  SeekInfo
+	done chan error
+}
+
+// Seek repositions the read cursor of a running Tail, like Config.Location
+// does at start, relative to the start or end of the file. Lines already
+// buffered in Lines, or read but not yet delivered, are discarded so that
+// the next line received is the one at pos. It returns ErrNotRunning if
+// tailing has stopped, e.g. because Follow is false and the end of the file
+// has been reached.
+func (tail *Tail) Seek(pos SeekInfo) error {
+	if err := checkWhence(pos.Whence); err != nil {
+		return err
+	}
+	req := seekRequest{pos: pos, done: make(chan error, 1)}
+	select {
+	case tail.seeks <- req:
+	case <-tail.Dying():
+		return ErrNotRunning
+	case <-tail.Dead():
+		return ErrNotRunning
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-tail.Dead():
+		return ErrNotRunning
+	}
+}
+
+// serveSeek handles a Seek request on the tailing goroutine.
+func (tail *Tail) serveSeek(req seekRequest) {
+	req.done <- tail.seekRunning(req.pos)
+}
+
+// drainLines discards the lines buffered in Lines.
+func (tail *Tail) drainLines() {
+	for {
+		select {
+		case <-tail.Lines:
+		default:
+			return
+		}
+	}
+}
+
+func (tail *Tail) seekRunning(pos SeekInfo) error {
+	if tail.gz != nil {
+		return fmt.Errorf("cannot seek in compressed %s", tail.Filename)
+	}
+	if _, err := tail.seeker(); err != nil {
+		return err
+	}
+	tail.drainLines()
+	pos, err := tail.resolveSeek(pos)
+	if err != nil {
+		return err
+	}
+	if pos.Offset, err = tail.includeLine(pos); err != nil {
+		return err
+	}
+	if err := tail.seekTo(pos); err != nil {
+		return err
+	}
+	// The byte before an arbitrary offset need not be a delimiter.
+	tail.lineEnd = false
+	tail.held = nil
+	tail.seeked = true
+	tail.setPosition(tail.offset)
+	return nil
+}
@@ -0,0 +1,39 @@
+package tail
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// seekToTime scans forward from the current offset for the first line at
+// or after t and seeks to its start.
+func (tail *Tail) seekToTime(t time.Time) error {
+	reader := tail.newReader(tail.fileReader())
+	offset := tail.offset
+	for {
+		line, err := reader.ReadBytes(tail.delimiter())
+		if err != nil {
+			// Not found: start after the last complete line.
+			if err != io.EOF {
+				return fmt.Errorf("error reading %s: %w", tail.Filename, err)
+			}
+			break
+		}
+		if ts, ok := tail.TimeParser(string(tail.trimDelimiter(line))); ok && !ts.Before(t) {
+			break
+		}
+		offset += int64(len(line))
+	}
+
+	seeker, err := tail.seeker()
+	if err != nil {
+		return err
+	}
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek error on %s: %s", tail.Filename, err)
+	}
+	tail.offset = offset
+	tail.Logger.Printf("Seeked %s to %v at offset %d", tail.Filename, t, offset)
+	return nil
+}
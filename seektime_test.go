@@ -0,0 +1,39 @@
+package tail
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseDay(line string) (time.Time, bool) {
+	day, _, _ := strings.Cut(line, " ")
+	ts, err := time.Parse("2006-01-02", day)
+	return ts, err == nil
+}
+
+func TestTail_SeekTime(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("2024-01-01 a\nnoise\n2024-01-02 b\nnoise\n2024-01-03 c\n")
+
+	seekTime := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	tailer, err := TailFile(testFile, Config{SeekTime: &seekTime, TimeParser: parseDay, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	var texts []string
+	for line := range tailer.Lines {
+		texts = append(texts, line.Text)
+	}
+	noError(t, tailer.Wait())
+	eq(t, texts, []string{"2024-01-02 b", "noise", "2024-01-03 c"})
+
+	// Nothing is recent enough, so only new lines are read.
+	seekTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tailer, err = TailFile(testFile, Config{Follow: true, SeekTime: &seekTime, TimeParser: parseDay, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	f.WriteString("2025-01-01 d\n")
+	eq(t, recvLine(t, tailer).Text, "2025-01-01 d")
+}
@@ -0,0 +1,71 @@
+//go:build go1.21
+
+package tail
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// SlogLogger adapts a *slog.Logger for use as Config.Logger. Messages are
+// logged at Info level; reopens, truncations and watcher errors are logged
+// with the event name as the message and filename, offset and error
+// attributes instead of a formatted message.
+type SlogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger returns a Config.Logger that logs to l.
+func NewSlogLogger(l *slog.Logger) *SlogLogger {
+	return &SlogLogger{l: l}
+}
+
+// LogEvent implements eventLogger.
+func (s *SlogLogger) LogEvent(err error, msg string, args ...any) {
+	if err != nil {
+		s.l.Warn(msg, append(args, "error", err)...)
+		return
+	}
+	s.l.Info(msg, args...)
+}
+
+func (s *SlogLogger) Print(v ...interface{}) { s.l.Info(fmt.Sprint(v...)) }
+func (s *SlogLogger) Printf(format string, v ...interface{}) {
+	s.l.Info(strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"))
+}
+func (s *SlogLogger) Println(v ...interface{}) { s.l.Info(sprintln(v...)) }
+
+func (s *SlogLogger) Fatal(v ...interface{}) {
+	s.l.Error(fmt.Sprint(v...))
+	os.Exit(1)
+}
+func (s *SlogLogger) Fatalf(format string, v ...interface{}) {
+	s.l.Error(fmt.Sprintf(format, v...))
+	os.Exit(1)
+}
+func (s *SlogLogger) Fatalln(v ...interface{}) {
+	s.l.Error(sprintln(v...))
+	os.Exit(1)
+}
+
+func (s *SlogLogger) Panic(v ...interface{}) {
+	msg := fmt.Sprint(v...)
+	s.l.Error(msg)
+	panic(msg)
+}
+func (s *SlogLogger) Panicf(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	s.l.Error(msg)
+	panic(msg)
+}
+func (s *SlogLogger) Panicln(v ...interface{}) {
+	msg := sprintln(v...)
+	s.l.Error(msg)
+	panic(msg)
+}
+
+func sprintln(v ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+}
@@ -0,0 +1,43 @@
+//go:build go1.21
+
+package tail
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTail_SlogLogger(t *testing.T) {
+	// Only the tailing goroutine logs, before sending the next line.
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, Logger: logger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, recvLine(t, tailer).Text, "hello")
+
+	noError(t, os.Rename(testFile, testFile+".1"))
+	noError(t, os.WriteFile(testFile, []byte("again\n"), 0600))
+	eq(t, recvLine(t, tailer).Text, "again")
+
+	out := buf.String()
+	for _, want := range []string{"msg=rotate filename=" + testFile + " offset=6", "msg=reopened filename=" + testFile + " offset=6"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in log output:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	logger.Printf("plain %s\n", "message")
+	if got := buf.String(); !strings.Contains(got, `msg="plain message"`) {
+		t.Errorf("unexpected log output %q", got)
+	}
+}
@@ -0,0 +1,48 @@
+package tail
+
+import "sync/atomic"
+
+// Stats counts what a Tail has done so far.
+type Stats struct {
+	LinesRead   uint64 // lines read, before any MaxLineSize split
+	BytesRead   uint64 // bytes of complete lines read, delimiters included
+	Reopens     uint64 // reopens after the file was moved or deleted
+	Truncations uint64 // reopens after the file was truncated
+	Errors      uint64 // lines sent with Err set
+	Dropped     uint64 // lines dropped by OverflowPolicy
+}
+
+// stats holds the counters behind Stats; they are updated by the tailing
+// goroutine and may be read concurrently.
+type stats struct {
+	linesRead   atomic.Uint64
+	bytesRead   atomic.Uint64
+	reopens     atomic.Uint64
+	truncations atomic.Uint64
+	errors      atomic.Uint64
+}
+
+// Stats returns a snapshot of the tail's counters. It is safe to call while
+// tailing.
+func (tail *Tail) Stats() Stats {
+	tail.stateLk.Lock()
+	defer tail.stateLk.Unlock()
+	s := Stats{
+		LinesRead:   tail.stats.linesRead.Load(),
+		BytesRead:   tail.stats.bytesRead.Load(),
+		Reopens:     tail.stats.reopens.Load(),
+		Truncations: tail.stats.truncations.Load(),
+		Errors:      tail.stats.errors.Load(),
+		Dropped:     tail.dropped.Load(),
+	}
+	if child := tail.newest; child != nil {
+		c := child.Stats()
+		s.LinesRead += c.LinesRead
+		s.BytesRead += c.BytesRead
+		s.Reopens += c.Reopens
+		s.Truncations += c.Truncations
+		s.Errors += c.Errors
+		s.Dropped += c.Dropped
+	}
+	return s
+}
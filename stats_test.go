@@ -0,0 +1,46 @@
+package tail
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTail_Stats(t *testing.T) {
+	testFile, f := testFile(t)
+	f.WriteString("hello\nworld\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, recvLine(t, tailer).Text, "hello")
+	eq(t, recvLine(t, tailer).Text, "world")
+
+	noError(t, f.Truncate(0))
+	deadline := time.Now().Add(5 * time.Second)
+	for tailer.Stats().Truncations == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for truncation")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	f.Seek(0, io.SeekStart)
+	f.WriteString("x\n")
+	eq(t, recvLine(t, tailer).Text, "x")
+
+	f.Close()
+	noError(t, os.Rename(testFile, testFile+".1"))
+	f, err = os.Create(testFile)
+	noError(t, err)
+	defer f.Close()
+	f.WriteString("y\n")
+	eq(t, recvLine(t, tailer).Text, "y")
+
+	eq(t, tailer.Stats(), Stats{
+		LinesRead:   4,
+		BytesRead:   16,
+		Reopens:     1,
+		Truncations: 1,
+	})
+}
@@ -6,13 +6,20 @@ package tail
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
-	"strings"
+	"path/filepath"
+	"regexp"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tenebris-tech/tail/ratelimiter"
@@ -28,20 +35,64 @@ var (
 
 type Line struct {
 	Text           string
+	Bytes          []byte // the line as read; not reused, so it can be retained
 	Time           time.Time
 	Err            error  // Error from tail
-	Offset         int64  // Offset of the beginning of the line in the file
+	Offset         int64  // Offset just past the line's delimiter; always a line boundary, safe to resume from
 	FileIdentifier string // unique identifier for the current file - OS specific
+	Filename       string // file the line was read from, with symlinks resolved at open
+	SourceFile     string // file the line was read from, set by TailNewest and MultiTail
+	Tag            string // Config.Name of the tail
+
+	// Num counts the lines sent from the current file, starting at 1. It
+	// restarts when the file is rotated or truncated, or after a line
+	// matching Config.ResetOnMatch; the first line after a restart has
+	// Reset set.
+	Num   int
+	Reset bool
+
+	// Truncated is set when the line was longer than MaxLineSize and cut
+	// short because TruncateLongLines is set.
+	Truncated bool
+
+	// Recovered marks a line emitted with EmitRecoveryMarkers after reading
+	// succeeded again following ErrorCount transient errors.
+	Recovered  bool
+	ErrorCount int
+
+	// RepeatCount is the number of identical consecutive lines this one
+	// stands for when Config.SuppressRepeats is set. Offset, Num and Time
+	// are those of the last of them.
+	RepeatCount int
+
+	// Partial is set on a final line that had no delimiter, sent at EOF
+	// without Follow or on stopping with EmitPartialOnStop. Its Offset is
+	// that of the start of the line.
+	Partial bool
 }
 
 // SeekInfo represents arguments to `os.Seek`
+//
+// Offset is where reading starts, so the first line read is the one that
+// starts at Offset. As a Line's Offset is just past its delimiter, resuming
+// from it continues with the next line and the line itself is not read
+// again, for exactly-once delivery. Set Inclusive to read the line ending
+// at Offset again too, for at-least-once delivery, or, with io.SeekEnd and
+// an Offset of 0, to start with the last line of the file.
 type SeekInfo struct {
 	Offset int64
-	Whence int // os.SEEK_*
+	Whence int // io.SeekStart or io.SeekEnd
+
+	// Inclusive, when Offset is at the end of a line, starts reading at
+	// the start of that line instead. It is ignored for pipes, readers
+	// and compressed files.
+	Inclusive bool
 
 	// FileIdentifier is an optional string to define the opaque identifier for the file offset.
 	// This allows only seeking if reading the same file as before.
 	// Populate using a value generated from Line.FileIdentifier.
+	// If the file was truncated since, so that Offset is past its end or
+	// no longer at the end of a line, it is read from the start instead.
 	FileIdentifier string
 }
 
@@ -57,6 +108,27 @@ type logger interface {
 	Println(v ...interface{})
 }
 
+// eventLogger is implemented by loggers that take key/value attributes,
+// such as SlogLogger.
+type eventLogger interface {
+	LogEvent(err error, msg string, args ...any)
+}
+
+// logEvent logs an event of the tail's file, as a message formatted from
+// format and v or, for an eventLogger, named event with filename, offset
+// and err as attributes.
+func (tail *Tail) logEvent(event string, err error, format string, v ...interface{}) {
+	if err != nil {
+		tail.reportError(err)
+	}
+	el, ok := tail.Logger.(eventLogger)
+	if !ok {
+		tail.Logger.Printf(format, v...)
+		return
+	}
+	el.LogEvent(err, event, "filename", tail.Filename, "offset", tail.offset)
+}
+
 // Config is used to specify how a file must be tailed.
 type Config struct {
 	// File-specifc
@@ -67,13 +139,370 @@ type Config struct {
 	Pipe        bool      // Is a named pipe (mkfifo)
 	RateLimiter *ratelimiter.LeakyBucket
 
+	// SeekEnd starts at the end of the file, so that only lines written
+	// from then on are read. It is short for a Location of
+	// SeekInfo{Offset: 0, Whence: io.SeekEnd} and cannot be combined with
+	// one. Like Location, it only applies to the first file opened: files
+	// reopened with ReOpen are read from the start.
+	SeekEnd bool
+
+	// LastNLines starts with the last LastNLines lines of the file, like
+	// tail -n, or all of them if it has fewer. A final line without a
+	// delimiter counts as one; with Follow, it is sent once complete. It
+	// cannot be combined with Location, SeekEnd or SeekTime, and with
+	// CheckpointPath only applies when no position has been saved. It is
+	// ignored for pipes, readers and compressed files.
+	LastNLines int
+
+	// AlignToLine moves a Location that falls inside a line, such as one
+	// saved while a line was only partly written, back to the start of
+	// that line. The first line sent is then whole, and its Offset is
+	// that of a real line boundary.
+	AlignToLine bool
+
+	// RateLimit, when positive, paces the lines sent on Lines to at most
+	// this many per second. Unlike RateLimiter, no lines are skipped.
+	RateLimit float64
+
+	// Watcher, when set, is used to wait for the file to change instead of
+	// inotify or polling, and cannot be combined with Poll. It watches this
+	// Tail's file only and must not be shared.
+	Watcher watch.FileWatcher
+
+	// IdleTimeout, when positive and ReOpen is not set, stops tailing with
+	// ErrIdleTimeout once the file has not been modified for this long.
+	IdleTimeout time.Duration
+
+	// EventCoalesceWindow, when positive, delays reading after the file is
+	// modified by up to this long, so that the writes made in the meantime
+	// are read in a single pass instead of waking up for each of them.
+	// Lines are delayed by at most the window.
+	EventCoalesceWindow time.Duration
+
+	// PollInterval is the time between polls when Poll is set. It defaults
+	// to watch.POLL_DURATION. It also paces the retries of opens and reads.
+	PollInterval time.Duration
+
+	// MinPollInterval and MaxPollInterval make polling adapt to the
+	// activity of the file: the time between polls starts at
+	// MinPollInterval, or PollInterval if it is not set, doubles after each
+	// poll that finds no change up to MaxPollInterval, and drops back after
+	// each change. They apply when polling, not to a custom Watcher.
+	MinPollInterval time.Duration
+	MaxPollInterval time.Duration
+
+	// DeletionConfirmDelay, when positive, makes polling check the file
+	// again after this delay before taking a failure to stat it as its
+	// deletion, so that transient permission errors on some overlay and
+	// read-only mounts do not cause a reopen. It does not apply to
+	// inotify.
+	DeletionConfirmDelay time.Duration
+
+	// SeekTime, with TimeParser, starts tailing at the first line whose
+	// time, as returned by TimeParser, is not before SeekTime. Lines the
+	// parser returns false for are skipped over. If no line qualifies,
+	// tailing starts after the last complete line. The scan starts at
+	// Location if both are set. It cannot be combined with Gzip.
+	SeekTime   *time.Time
+	TimeParser func(line string) (time.Time, bool)
+
+	// MaxWaitBackoff, when greater than the polling interval, makes the
+	// wait for a missing file check less and less often, doubling the time
+	// between checks up to MaxWaitBackoff. Each wait starts over at the
+	// polling interval. It does not apply to a custom Watcher.
+	MaxWaitBackoff time.Duration
+
+	// WaitForFileTimeout, if non-zero, limits how long to wait for a
+	// missing file to first appear before stopping with ErrFileTimeout.
+	WaitForFileTimeout time.Duration
+
+	// MaxReopenAttempts, if non-zero, limits how many times ReOpen tries to
+	// reopen a file that is missing or not a regular file before stopping
+	// with ErrReopenFailed. Each attempt waits ReopenBackoff for the file,
+	// or the polling interval if zero, doubling after every attempt.
+	MaxReopenAttempts int
+	ReopenBackoff     time.Duration
+
+	// FollowSymlinkTarget, with ReOpen, also reopens the file when
+	// Filename is a symlink that is repointed to another file, as container
+	// runtimes do with their log symlinks. Polling always notices this;
+	// with inotify only the file the symlink resolved to is watched unless
+	// this is set.
+	FollowSymlinkTarget bool
+
+	// WatchDir, with inotify, also watches the directory of the file, so
+	// that a file created in its place after a rotation is opened at once
+	// instead of at the next poll. Without it, or if the directory cannot
+	// be watched, the file is polled for.
+	WatchDir bool
+
+	// WaitForReadable treats a permission error on open like a file that
+	// does not exist yet: the open is retried with backoff until the file
+	// becomes readable instead of failing.
+	WaitForReadable bool
+
+	// EmitRecoveryMarkers sends a Line with Recovered set once reading
+	// succeeds again after transient read errors. Read errors are only
+	// retried with ReOpen.
+	EmitRecoveryMarkers bool
+
+	// SeparateErrors sends the errors that do not stop tailing, such as
+	// read errors being retried or inotify failing over to polling, on
+	// Tail.Errors instead of as Lines with Err set, so that Lines only
+	// carries data. Errors that stop tailing are still returned by Wait.
+	SeparateErrors bool
+
+	// UseInodeGeneration adds the inode generation number to the file
+	// identifier where the filesystem exposes it (ext4, XFS), so a file
+	// that reuses the inode of a deleted one is not mistaken for it.
+	UseInodeGeneration bool
+
+	// DetectInPlaceReset, with Follow, catches a file that is overwritten
+	// in place without changing its size or identity, such as one zeroed
+	// by a rotation scheme. A checksum of the bytes before the read
+	// position is kept while waiting at the end of the file and checked
+	// again on every change and poll interval; on a mismatch the file is
+	// read again from the start as if truncated. It costs a small read
+	// each time.
+	DetectInPlaceReset bool
+
+	// Gzip reads the file as a gzip archive and sends its decompressed
+	// lines. It cannot be combined with Follow. Offsets count decompressed
+	// bytes and Location is ignored. Files are never decompressed unless it
+	// is set, whatever their name.
+	Gzip bool
+
+	// TolerateTruncatedGzip, when replaying a gzip archive with Gzip,
+	// sends every line decompressed before an archive that was cut short
+	// ends, followed by a single error Line, instead of failing the tail.
+	TolerateTruncatedGzip bool
+
+	// ReadCompressedRotations, when the file is rotated or truncated, reads
+	// any lines not yet read from a <name>.1.gz already holding its
+	// content before switching to the new file. Offsets of those lines are
+	// relative to the decompressed stream.
+	ReadCompressedRotations bool
+
+	// OnGzipHeader, when set, is called with the name of each gzip archive
+	// read, whether replayed, a compressed rotation or caught up with
+	// CatchUpRotated, and the Extra field of its header, such as the
+	// metadata written there by Docker's log rotation. It runs on the
+	// tailing goroutine before any line of the archive is sent.
+	OnGzipHeader func(name string, extra []byte)
+
+	// CatchUpRotated, with no Location or SeekTime, first reads the
+	// rotations of the file left from before tailing started: <name>.1 or
+	// <name>.1.gz, <name>.2 or <name>.2.gz and so on, oldest first, and
+	// then the file itself. Each is read like a new file: Offset and Num
+	// start over and Filename is the path of the rotation.
+	CatchUpRotated bool
+
+	// NumericRotation makes TailNewest follow the matching file with the
+	// highest number in its name rather than the most recently modified
+	// one, switching over as higher-numbered files appear, like the
+	// 0.log, 1.log, ... a Kubernetes container runtime writes to a pod's
+	// log directory on each container restart. OnReopen is called at each
+	// switch with the identifiers of the old and new files.
+	NumericRotation bool
+
+	// EmitPartialOnStop, with Follow, sends any final line without a
+	// delimiter, with Line.Partial set, when tailing stops at the end of
+	// the file. The line is sent before Lines is closed, so Stop waits up
+	// to a second for it to be received unless Lines has room for it, and
+	// then drops it.
+	EmitPartialOnStop bool
+
+	// Opener, when set, opens the file instead of OpenFile, for instance to
+	// pass extra flags or inject faults. It may return a nil FileInfo, in
+	// which case the file is stated. A missing file must be reported with
+	// an error satisfying os.IsNotExist so that it is waited for.
+	// NonBlockingOpen is then ignored.
+	Opener func(name string) (*os.File, fs.FileInfo, error)
+
+	// NonBlockingOpen opens the file with O_NONBLOCK on Unix, so that with
+	// Pipe a FIFO without a writer is opened at once instead of blocking
+	// until one connects. Reads that find no data return as if at the end
+	// of the file and tailing waits for changes, so Stop is not held up by
+	// an idle writer. It has no effect on Windows or on regular files.
+	NonBlockingOpen bool
+
 	// Generic IO
-	Follow      bool // Continue looking for new lines (tail -f)
+	Follow      bool // Continue looking for new lines (tail -f); otherwise close Lines at EOF
 	MaxLineSize int  // If non-zero, split longer lines into multiple lines
 
-	// Logger, when nil, is set to tail.DefaultLogger
-	// To disable logging: set field to tail.DiscardingLogger
+	// TruncateLongLines cuts lines longer than MaxLineSize instead of
+	// splitting them, setting Line.Truncated. The rest of the line is
+	// discarded while it is read, so at most MaxLineSize bytes of it are
+	// held in memory. It cannot be combined with JSONMode or a UTF-16
+	// Encoding.
+	TruncateLongLines bool
+
+	// MaxBytes and MaxRuntime, when non-zero, bound a tailing job. Once
+	// MaxBytes have been read or MaxRuntime has passed since TailFile,
+	// whichever comes first, tailing stops like StopAtEOF: the lines read
+	// so far are delivered, the partial line of EmitPartialOnStop included,
+	// the position is checkpointed, Lines is closed, Wait returns nil and
+	// StopReason reports which limit was hit.
+	//
+	// No more than MaxBytes, delimiters included, are sent. A line that
+	// would go over is not, unless EmitPartialOnStop is set, in which case
+	// the part of it that fits is sent with Line.Partial set. The last
+	// Line sent then has Err set to ErrByteQuotaExceeded.
+	MaxBytes   int64
+	MaxRuntime time.Duration
+
+	// Delimiter is the single byte that ends a line, "\n" if empty. A
+	// string is used so that NUL ("\x00") can be told apart from unset.
+	Delimiter string
+
+	// SkipLines discards that many lines, such as a header, whenever a file
+	// is read from its start: when first opened, unless Location is past
+	// the start, and when reopened after a rotation or truncation. The
+	// Num and Offset of the lines sent still count the skipped ones.
+	SkipLines int
+
+	// ReadBufferSize is the size of the buffer the file is read through,
+	// 4096 bytes by default, so that a busy file is read in fewer, larger
+	// reads. Lines are split from it in memory and offsets are unaffected.
+	// It is raised to MaxLineSize+2 if smaller.
+	ReadBufferSize int
+
+	// JSONMode, for logs of JSON values, only ends a line at a delimiter
+	// once the top-level value read so far is complete, so that a value
+	// pretty-printed over several lines is sent as one Line with its
+	// inner delimiters kept. With MaxLineSize, a value still incomplete
+	// past that size, as when malformed, is ended at the next delimiter.
+	// It is ignored for UTF-16 files.
+	JSONMode bool
+
+	// OmitText leaves Line.Text empty, saving a copy of each line for
+	// callers that only use Line.Bytes.
+	OmitText bool
+
+	// MaxBufferedLines is the capacity of Lines. OverflowPolicy decides
+	// what happens when a line is read while Lines is full: Block (the
+	// default) waits for the consumer, DropNewest discards the new line and
+	// DropOldest discards the oldest buffered one, counting them in
+	// Dropped. Neither drop policy needs a goroutine beyond the tailer's.
+	//
+	// Lines is unbuffered by default. A buffer lets the tailer read ahead
+	// through bursts of writes without waiting on the consumer for each
+	// line, at the cost of holding up to MaxBufferedLines lines in memory.
+	// Lines count as delivered, for Position and PositionStore, once they
+	// are buffered, so any still buffered when the process dies are lost.
+	MaxBufferedLines int
+	OverflowPolicy   OverflowPolicy
+
+	// KeepChannelOpen leaves Lines open once tailing stops, so that another
+	// Tail can be given it as Channel and send on it in turn. Ranging over
+	// Lines then never ends: use Wait or Dead to learn that tailing has
+	// stopped. The channel is the caller's, who must make sure that only
+	// one Tail sends on it at a time and may close it once the last one
+	// has stopped. MultiTail ignores it.
+	KeepChannelOpen bool
+
+	// Channel, when set, is used as Lines instead of a new channel, and
+	// MaxBufferedLines is ignored. It is typically the Lines of a stopped
+	// Tail that had KeepChannelOpen set. MultiTail ignores it. It cannot be
+	// combined with DropOldest, which could discard the lines another Tail
+	// left buffered in it.
+	Channel chan *Line
+
+	// Encoding is the encoding of the file, UTF8 by default. UTF-16 lines
+	// are decoded to UTF-8 while offsets stay byte offsets into the file,
+	// and a leading byte order mark is dropped.
+	Encoding Encoding
+
+	// TrimCR removes a carriage return before the delimiter, as in CRLF
+	// line endings. A carriage return anywhere else is kept.
+	TrimCR bool
+
+	// StripANSI removes ANSI escape sequences, such as the SGR codes of
+	// colored output, from Line.Text. Line.Bytes and Offset still reflect
+	// the bytes read.
+	StripANSI bool
+
+	// KeepDelimiter leaves the trailing delimiter (including any preceding
+	// carriage return) on Line.Text exactly as it was read.
+	KeepDelimiter bool
+
+	// LineFilter, when set, is called with each line before it is sent
+	// and drops the line if it returns false. Dropped lines still advance
+	// the position and Num. It runs on the tailing goroutine, so it should
+	// be fast.
+	LineFilter func(*Line) bool
+
+	// MatchRegexp, when set, drops the lines that do not match it, before
+	// LineFilter is called. It is matched against each line as read, so
+	// with StripANSI escape sequences can get in the way. Dropped lines
+	// still advance the position and Num. Matching runs on the tailing
+	// goroutine for every line, so on a busy file an expensive pattern
+	// slows down tailing; a literal prefix or substring keeps it cheap.
+	MatchRegexp *regexp.Regexp
+
+	// SuppressRepeats collapses identical consecutive lines into one,
+	// with Line.RepeatCount set to their number, like syslog's "last
+	// message repeated N times". A line is held back until a different
+	// line is read or the end of the file is reached.
+	SuppressRepeats bool
+
+	// ResetOnMatch, when set, restarts Line.Num after a matching line such
+	// as a "LOG RESET" control line. The matching line is still sent.
+	ResetOnMatch *regexp.Regexp
+
+	// OnReopen, when set, is called with the old and new file identifiers
+	// whenever the file is reopened after a rotation or truncation. It runs
+	// on the tailing goroutine before any line of the reopened file is sent.
+	OnReopen func(oldID, newID string)
+
+	// OnFileAppear, when set, is called with Filename once a file that did
+	// not exist when tailing started has appeared and been opened. It runs
+	// on the tailing goroutine before any line of the file is read.
+	OnFileAppear func(name string)
+
+	// OnTruncate, when set, is called when the file is found truncated,
+	// or overwritten in place, with the size it had been read up to and
+	// its size once reopened. It runs on the tailing goroutine before
+	// OnReopen and before the file is read again from the start.
+	OnTruncate func(oldSize, newSize int64)
+
+	// OnRawRead, when set, is called with the exact bytes of each line
+	// consumed from the file, delimiter included and before any trimming or
+	// MaxLineSize splitting, so concatenating the chunks reproduces the file
+	// content read. The slice is only valid for the duration of the call.
+	OnRawRead func(chunk []byte)
+
+	// Logger, when nil, is set to tail.DiscardLogger, so nothing is logged.
+	// To log to stderr: set field to tail.DefaultLogger
+	// For structured logging: set field to tail.NewSlogLogger(l)
 	Logger logger
+
+	// Name, when set, identifies the tail: messages logged are prefixed
+	// with "[Name] ", or given a "name" attribute by a structured logger,
+	// and lines sent have it as Line.Tag.
+	Name string
+
+	// Tracer, when set, is used to trace opening and reopening the file and
+	// replaying archives.
+	Tracer Tracer
+
+	// PositionStore, when set, receives checkpoints of the position past
+	// the last delivered line. A checkpoint is written every
+	// CheckpointInterval, after every CheckpointEveryNLines lines, whichever
+	// comes first, and always when tailing stops.
+	PositionStore         PositionStore
+	CheckpointInterval    time.Duration
+	CheckpointEveryNLines int
+
+	// CheckpointPath, when set, makes the PositionStore a CheckpointFile
+	// at this path, and tailing resume from the position saved there if
+	// there is one and neither Location, SeekEnd nor SeekTime is set. As
+	// the position carries the FileIdentifier, a file replaced since is
+	// read from the start. It cannot be combined with PositionStore, nor
+	// used with MultiTail, whose files would share it.
+	CheckpointPath string
+
+	clock clock // the wall clock if nil; set by tests
 }
 
 type Tail struct {
@@ -81,23 +510,79 @@ type Tail struct {
 	Lines    chan *Line
 	Config
 
+	// Errors receives the errors that did not stop tailing when
+	// SeparateErrors is set, and is nil otherwise. It is buffered and
+	// errors that do not fit are dropped rather than holding up tailing.
+	// It is closed with Lines.
+	Errors <-chan error
+	errs   chan error
+
 	file           *os.File
 	reader         *bufio.Reader
 	fileIdentifier string // unique identifier for the current file - OS specific
+	openedName     string // Filename with symlinks resolved when it was opened
+	drainedSize    int64  // file size when StopAtEOF last checked for more data
+	offset         int64  // offset of the last complete line read from the current file
+	eofOffset      int64  // offset at which EOF was last reached, including any partial line
+	readErrors     int    // consecutive transient read errors
+	bytesRead      int64  // bytes of complete lines read, for MaxBytes
+	num            int    // Num of the last line sent
+	resetPending   bool   // next line sent has Reset set
+	lineEnd        bool   // offset has been just past a delimiter read from the file
+	seeked         bool   // Seek moved the cursor while a line was being sent
+	partial        bool   // the line being sent has no delimiter
+	pending        []byte // start of a line read from a pipe, see NonBlockingOpen
+	resetSum       resetSum
+	skipLeft       int   // lines still to be skipped, see SkipLines
+	held           *Line // line held back by SuppressRepeats
+	final          bool  // the line is sent even though tailing is stopping, see EmitPartialOnStop
+
+	seeks   chan seekRequest   // see Seek
+	reloads chan reloadRequest // see Reload
+
+	source io.Reader    // read instead of file, set by TailReader
+	gz     *gzip.Reader // decompressor when replaying a .gz file
+
+	nextSend time.Time // earliest time the next line may be sent, see RateLimit
+	modTime  time.Time // latest modification time seen of the open file, see rotated
 
-	watcher watch.FileWatcher
 	changes *watch.FileChanges
 
 	tomb.Tomb // provides: Done, Kill, Dying
 
-	lk sync.Mutex
+	dropped atomic.Uint64 // lines dropped by OverflowPolicy
+	atEOF   atomic.Bool   // see AtEOF
+	stats   stats
+
+	byName bool // TailNewest: pick the file by name, see TailGlob
+
+	lk sync.Mutex // guards the file and reader; held while reading
+
+	// stateLk guards the state below. Unlike lk it is never held while
+	// reading, which may block on a pipe or reader indefinitely.
+	stateLk    sync.Mutex
+	watcher    watch.FileWatcher
+	quiesced   bool          // keep the file open after stopping, see Quiesce
+	stopReason StopReason    // limit that stopped tailing, see StopReason
+	resume     chan struct{} // closed by Resume, nil unless paused
+	newest     *Tail         // TailNewest: the tail of the current file
+
+	closeOnce sync.Once // closes the file of a quiesced Tail in Cleanup
+
+	ckLk                 sync.Mutex // guards the checkpoint state below
+	position             SeekInfo   // position past the last delivered line
+	positionSet          bool       // position has been set since the file was opened
+	positionDirty        bool       // position changed since the last checkpoint
+	linesSinceCheckpoint int
 }
 
 var (
-	// DefaultLogger is used when Config.Logger == nil
+	// DefaultLogger logs to stderr
 	DefaultLogger = log.New(os.Stderr, "", log.LstdFlags)
 	// DiscardingLogger can be used to disable logging output
 	DiscardingLogger = log.New(io.Discard, "", 0)
+	// DiscardLogger is used when Config.Logger == nil; same as DiscardingLogger
+	DiscardLogger = DiscardingLogger
 )
 
 // TailFile begins tailing the file. Output stream is made available
@@ -105,37 +590,160 @@ var (
 // invoke the `Wait` or `Err` method after finishing reading from the
 // `Lines` channel.
 func TailFile(filename string, config Config) (*Tail, error) {
-	if config.ReOpen && !config.Follow {
-		util.Fatal("cannot set ReOpen without Follow.")
+	t, err := newTail(filename, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Watcher != nil {
+		t.watcher = t.Watcher
+	} else if t.Poll {
+		t.watcher = t.newPollingWatcher()
+	} else {
+		t.watcher = newInotifyWatcher(filename)
+		if fw, ok := t.watcher.(*watch.InotifyFileWatcher); ok {
+			fw.WatchLink = t.FollowSymlinkTarget
+			fw.WatchDir = t.WatchDir
+			fw.MaxBackoff = t.MaxWaitBackoff
+		}
+	}
+
+	if t.MustExist {
+		err := t.openFile()
+		if err != nil && !(t.WaitForReadable && os.IsPermission(err)) {
+			return nil, err
+		}
+	}
+
+	t.start()
+	return t, nil
+}
+
+// MustTailFile is like TailFile but panics if the file cannot be tailed.
+// It is meant for tests and one-off programs, not for code that needs to
+// handle the error.
+func MustTailFile(filename string, config Config) *Tail {
+	t, err := TailFile(filename, config)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// TailReader reads lines from r like TailFile reads a file without Follow,
+// until r returns EOF. Location, SeekEnd and SeekTime are only supported if
+// r is also an io.Seeker.
+func TailReader(r io.Reader, config Config) (*Tail, error) {
+	config.Follow, config.ReOpen = false, false
+	if _, ok := r.(io.Seeker); !ok && (config.Location != nil || config.SeekEnd || config.SeekTime != nil) {
+		return nil, errors.New("tail: Location, SeekEnd and SeekTime need an io.Seeker")
+	}
+	t, err := newTail("", config)
+	if err != nil {
+		return nil, err
+	}
+	t.source = r
+
+	t.start()
+	return t, nil
+}
+
+// TailStdin reads lines from standard input like TailReader, until it is
+// closed. Standard input cannot be relied upon to be seekable, so Location,
+// SeekEnd and SeekTime are ignored.
+func TailStdin(config Config) (*Tail, error) {
+	config.Location, config.SeekEnd, config.SeekTime = nil, false, nil
+	return TailReader(os.Stdin, config)
+}
+
+// newPollingWatcher returns the watcher used when polling.
+func (tail *Tail) newPollingWatcher() *watch.PollingFileWatcher {
+	fw := watch.NewPollingFileWatcher(tail.Filename)
+	fw.Interval = tail.pollInterval()
+	fw.MaxBackoff = tail.MaxWaitBackoff
+	fw.MaxInterval = tail.MaxPollInterval
+	fw.DeletionConfirmDelay = tail.DeletionConfirmDelay
+	fw.Clock = tail.clock
+	return fw
+}
+
+// pollInterval returns the time between polls right after a change, which
+// is also how long the waits and retries that are not driven by the watcher
+// sleep for.
+func (tail *Tail) pollInterval() time.Duration {
+	if tail.MinPollInterval > 0 {
+		return tail.MinPollInterval
+	}
+	if tail.PollInterval > 0 {
+		return tail.PollInterval
+	}
+	return watch.POLL_DURATION
+}
+
+// newTail validates config and creates a Tail that has not started yet.
+func newTail(filename string, config Config) (*Tail, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.useCheckpointFile(); err != nil {
+		return nil, err
+	}
+	if config.SeekEnd {
+		config.Location = &SeekInfo{Offset: 0, Whence: io.SeekEnd}
 	}
 
 	t := &Tail{
 		Filename: filename,
-		Lines:    make(chan *Line),
+		Lines:    config.Channel,
 		Config:   config,
+		seeks:    make(chan seekRequest),
+		reloads:  make(chan reloadRequest),
 	}
-
-	// when Logger was not specified in config, use default logger
-	if t.Logger == nil {
-		t.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	if t.Lines == nil {
+		t.Lines = make(chan *Line, config.MaxBufferedLines)
+	}
+	if config.SeparateErrors {
+		t.errs = make(chan error, errorsBuffer)
+		t.Errors = t.errs
 	}
 
-	if t.Poll {
-		t.watcher = watch.NewPollingFileWatcher(filename)
-	} else {
-		t.watcher = watch.NewInotifyFileWatcher(filename)
+	if t.clock == nil {
+		t.clock = realClock{}
 	}
 
-	if t.MustExist {
-		var err error
-		t.file, t.fileIdentifier, err = OpenFile(t.Filename)
-		if err != nil {
-			return nil, err
-		}
+	// when Logger was not specified in config, don't log
+	if t.Logger == nil {
+		t.Logger = DiscardLogger
 	}
+	t.Logger = withName(t.Logger, t.Name)
+	return t, nil
+}
 
-	go t.tailFileSync()
+// start starts the goroutines of a Tail created by newTail.
+func (tail *Tail) start() {
+	if tail.PositionStore != nil && tail.CheckpointInterval > 0 {
+		go tail.checkpointEvery()
+	}
+	if tail.MaxRuntime > 0 {
+		go tail.stopAfter(tail.MaxRuntime)
+	}
+	go tail.tailFileSync()
+}
 
+// TailFileContext is like TailFile, but tailing stops when ctx is done.
+// Lines is then closed and Wait returns ctx.Err().
+func TailFileContext(ctx context.Context, filename string, config Config) (*Tail, error) {
+	t, err := TailFile(filename, config)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			t.Kill(ctx.Err())
+		case <-t.Dying():
+		}
+	}()
 	return t, nil
 }
 
@@ -144,6 +752,11 @@ func TailFile(filename string, config Config) (*Tail, error) {
 // it may readed one line in the chan(tail.Lines),
 // so it may lost one line.
 func (tail *Tail) Tell() (offset int64, err error) {
+	if child := tail.newestTail(); child != nil {
+		return child.Tell()
+	}
+	tail.lk.Lock()
+	defer tail.lk.Unlock()
 	if tail.file == nil {
 		return
 	}
@@ -151,9 +764,6 @@ func (tail *Tail) Tell() (offset int64, err error) {
 	if err != nil {
 		return
 	}
-
-	tail.lk.Lock()
-	defer tail.lk.Unlock()
 	if tail.reader == nil {
 		return
 	}
@@ -162,13 +772,143 @@ func (tail *Tail) Tell() (offset int64, err error) {
 	return
 }
 
-// Stop stops the tailing activity.
+// AtEOF reports whether the last read reached the end of the file with no
+// partial line left over, i.e. whether tailing has caught up with the
+// writer. The lines read up to there may still be buffered in Lines. It is
+// safe to call from any goroutine.
+func (tail *Tail) AtEOF() bool {
+	if child := tail.newestTail(); child != nil {
+		return child.AtEOF()
+	}
+	return tail.atEOF.Load()
+}
+
+// Lag returns the number of bytes of the file being read past the last
+// line delivered on Lines, as reported by Position. It is measured against
+// the file currently open, so once a rotated file has been reopened it is
+// the backlog of the new file. It is 0 before the file is opened and after
+// it is closed.
+func (tail *Tail) Lag() (int64, error) {
+	if child := tail.newestTail(); child != nil {
+		return child.Lag()
+	}
+	tail.lk.Lock()
+	file := tail.file
+	var fi os.FileInfo
+	var err error
+	if file != nil {
+		// Under lk so that the file is not closed meanwhile.
+		fi, err = file.Stat()
+	}
+	tail.lk.Unlock()
+	if file == nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	pos, err := tail.Position()
+	if err == errNotOpened {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	// Just after a reopen, the position may still be that of the old file.
+	if lag := fi.Size() - pos.Offset; lag > 0 {
+		return lag, nil
+	}
+	return 0, nil
+}
+
+// Wait blocks until tailing has stopped and Lines has been closed, unless
+// KeepChannelOpen is set, and
+// returns the error that stopped it, or nil if it was stopped on request or
+// finished normally. It may be called from several goroutines, and returns
+// the same error each time.
+func (tail *Tail) Wait() error {
+	err := tail.Tomb.Wait()
+	if err == errStopAtEOF {
+		return nil
+	}
+	return err
+}
+
+// Stop stops the tailing activity. It may be called any number of times,
+// from several goroutines and in any order with Cleanup. A line the tailer
+// is blocked sending is dropped, so Stop does not wait for a consumer that
+// no longer reads Lines. Only the final line of EmitPartialOnStop is
+// waited for, and then for no more than a second.
 func (tail *Tail) Stop() error {
 	tail.Kill(nil)
 	return tail.Wait()
 }
 
-// StopAtEOF stops tailing as soon as the end of the file is reached.
+// Quiesce stops the tailing activity like Stop, but keeps the file open so
+// that Tell and Lag can still be queried. Call Cleanup to close the file
+// afterwards; otherwise it is only closed once the Tail is garbage
+// collected.
+func (tail *Tail) Quiesce() error {
+	tail.stateLk.Lock()
+	tail.quiesced = true
+	tail.stateLk.Unlock()
+	return tail.Stop()
+}
+
+// Pause suspends reading until Resume is called. The file stays open and
+// watched and the position is kept; a line being sent when Pause is called
+// is still delivered, but no further ones. Seek may be called while paused.
+func (tail *Tail) Pause() {
+	tail.stateLk.Lock()
+	defer tail.stateLk.Unlock()
+	if tail.resume == nil {
+		tail.resume = make(chan struct{})
+	}
+	if tail.newest != nil {
+		tail.newest.Pause()
+	}
+}
+
+// Resume resumes reading after Pause.
+func (tail *Tail) Resume() {
+	tail.stateLk.Lock()
+	defer tail.stateLk.Unlock()
+	if tail.resume != nil {
+		close(tail.resume)
+		tail.resume = nil
+	}
+	if tail.newest != nil {
+		tail.newest.Resume()
+	}
+}
+
+// waitWhilePaused blocks while the tail is paused. It returns false if the
+// tail is stopped meanwhile.
+func (tail *Tail) waitWhilePaused() bool {
+	tail.stateLk.Lock()
+	resume := tail.resume
+	tail.stateLk.Unlock()
+	if resume == nil {
+		return true
+	}
+	for {
+		select {
+		case <-resume:
+			return true
+		case req := <-tail.seeks:
+			tail.serveSeek(req)
+		case req := <-tail.reloads:
+			tail.serveReload(req)
+		case <-tail.Dying():
+			return false
+		}
+	}
+}
+
+// StopAtEOF stops tailing as soon as the end of the file is reached, after
+// the lines written so far have been sent, and waits for Lines to be closed
+// like Stop. As those lines must be received meanwhile, call it from another
+// goroutine than the one reading Lines, unless Lines has room for them.
 func (tail *Tail) StopAtEOF() error {
 	tail.Kill(errStopAtEOF)
 	return tail.Wait()
@@ -176,109 +916,547 @@ func (tail *Tail) StopAtEOF() error {
 
 var errStopAtEOF = errors.New("tail: stop at eof")
 
-func (tail *Tail) close() {
-	close(tail.Lines)
-	tail.closeFile()
+// OverflowPolicy decides what happens to lines read while Lines is full.
+type OverflowPolicy int
+
+const (
+	Block      OverflowPolicy = iota // wait for the consumer
+	DropOldest                       // drop the oldest buffered line
+	DropNewest                       // drop the line just read
+)
+
+// StopReason records which limit, if any, stopped tailing.
+type StopReason int
+
+const (
+	NotStopped StopReason = iota // no limit has been reached
+	ByteLimit                    // Config.MaxBytes was reached
+	TimeLimit                    // Config.MaxRuntime has passed
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case ByteLimit:
+		return "byte limit"
+	case TimeLimit:
+		return "time limit"
+	}
+	return "not stopped"
 }
 
-func (tail *Tail) closeFile() {
-	if tail.file != nil {
-		_ = tail.file.Close()
-		tail.file = nil
+// StopReason returns the limit that stopped tailing, or NotStopped.
+func (tail *Tail) StopReason() StopReason {
+	tail.stateLk.Lock()
+	defer tail.stateLk.Unlock()
+	if tail.stopReason == NotStopped && tail.newest != nil {
+		return tail.newest.StopReason()
 	}
+	return tail.stopReason
 }
 
-func (tail *Tail) reopen() error {
-	tail.closeFile()
-	for {
-		var err error
-		tail.file, tail.fileIdentifier, err = OpenFile(tail.Filename)
-		if err != nil {
-			if os.IsNotExist(err) {
-				tail.Logger.Printf("Waiting for %s to appear...", tail.Filename)
-				if err := tail.watcher.BlockUntilExists(&tail.Tomb); err != nil {
-					if err == tomb.ErrDying {
-						return err
-					}
-					return fmt.Errorf("failed to detect creation of %s: %s", tail.Filename, err)
-				}
-				continue
-			}
-			return fmt.Errorf("unable to open file %s: %s", tail.Filename, err)
-		}
-		break
+// stopWithReason stops tailing at EOF because a limit was reached.
+func (tail *Tail) stopWithReason(reason StopReason) {
+	tail.stateLk.Lock()
+	if tail.stopReason == NotStopped {
+		tail.stopReason = reason
 	}
-	return nil
+	tail.stateLk.Unlock()
+	tail.Kill(errStopAtEOF)
 }
 
-func (tail *Tail) readLine() (string, int64, error) {
-	tail.lk.Lock()
-	line, err := tail.reader.ReadString('\n')
-	tail.lk.Unlock()
+// ErrByteQuotaExceeded is sent as the Err of the last Line once
+// Config.MaxBytes have been read.
+var ErrByteQuotaExceeded = errors.New("tail: byte quota exceeded")
 
-	read := int64(len(line))
-	if err != nil {
-		// Note ReadString "returns the data read before the error" in
-		// case of an error, including EOF, so we return it as is. The
-		// caller is expected to process it if err is EOF.
-		return line, read, err
+// stopAtByteLimit stops tailing once MaxBytes have been read. over is the
+// line that did not fit, if any.
+func (tail *Tail) stopAtByteLimit(over []byte) {
+	if n := tail.MaxBytes - tail.bytesRead; len(over) > 0 && n > 0 && tail.EmitPartialOnStop {
+		if int64(len(over)) > n {
+			over = over[:n]
+		}
+		tail.bytesRead += int64(len(over))
+		tail.sendPartial(over, false)
 	}
+	tail.flushRepeat()
+	tail.send(&Line{Time: tail.clock.Now(), Err: ErrByteQuotaExceeded, Offset: tail.offset, FileIdentifier: tail.fileIdentifier})
+	tail.stopWithReason(ByteLimit)
+}
 
-	line = strings.TrimRight(line, "\n")
+// stopAfter stops tailing with TimeLimit once d has passed.
+func (tail *Tail) stopAfter(d time.Duration) {
+	timer, stop := tail.clock.NewTimer(d)
+	defer stop()
+	select {
+	case <-timer:
+		tail.stopWithReason(TimeLimit)
+	case <-tail.Dying():
+	}
+}
 
-	return line, read, err
+// ReadError is sent as Line.Err when reading the file fails. With ReOpen
+// the read is retried, from Offset, up to maxReadRetries times in a row;
+// otherwise, or once the retries are exhausted, tailing stops with it.
+type ReadError struct {
+	Filename string
+	Offset   int64 // offset of the last complete line read
+	Err      error
 }
 
-func (tail *Tail) tailFileSync() {
-	defer tail.Done()
-	defer tail.close()
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("error reading %s: %s", e.Filename, e.Err)
+}
 
-	if !tail.MustExist {
-		// deferred first open.
-		err := tail.reopen()
-		if err != nil {
-			if err != tomb.ErrDying {
-				tail.Kill(err)
-			}
-			return
-		}
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// ErrIdleTimeout is the error tailing stops with when the file has not
+// been modified within Config.IdleTimeout.
+var ErrIdleTimeout = errors.New("tail: file idle")
+
+// ErrNotRegularFile is the error tailing stops with when the file has been
+// replaced by a directory, FIFO or socket. With ReOpen, it is sent once as
+// Line.Err instead and a regular file is waited for.
+var ErrNotRegularFile = errors.New("tail: not a regular file")
+
+// notRegular reports whether a file of the given mode cannot be tailed.
+// Devices are allowed, and FIFOs with Pipe.
+func notRegular(mode os.FileMode, pipe bool) bool {
+	if pipe && mode&os.ModeNamedPipe != 0 {
+		return false
 	}
+	return mode&(os.ModeDir|os.ModeNamedPipe|os.ModeSocket) != 0
+}
 
-	var offset int64 = 0
+// ErrFileTimeout is the error tailing stops with when the file does not
+// appear within Config.WaitForFileTimeout.
+var ErrFileTimeout = errors.New("tail: timed out waiting for file")
 
-	// Seek to requested location on first open of the file.
-	if tail.Location != nil {
-		if tail.Location.FileIdentifier == "" || tail.Location.FileIdentifier == tail.fileIdentifier {
-			_, err := tail.file.Seek(tail.Location.Offset, tail.Location.Whence)
-			tail.Logger.Printf("Seeked %s - %+v\n", tail.Filename, tail.Location)
-			if err != nil {
-				_ = tail.Killf("Seek error on %s: %s", tail.Filename, err)
-				return
-			}
-			offset = tail.Location.Offset
-		} else {
-			tail.Logger.Printf("Skipping seek because fileIdentifier %q does not match requested FileIdentifier %q", tail.fileIdentifier, tail.Location.FileIdentifier)
+// ErrReopenFailed is the error tailing stops with when the file could not
+// be reopened within Config.MaxReopenAttempts.
+var ErrReopenFailed = errors.New("tail: reopen attempts exhausted")
+
+// blockUntilExists waits for the file to exist, failing with
+// ErrFileTimeout after timeout unless it is zero.
+func (tail *Tail) blockUntilExists(timeout time.Duration) error {
+	if timeout <= 0 {
+		return tail.watcher.BlockUntilExists(&tail.Tomb)
+	}
+
+	var wait tomb.Tomb
+	timer, stop := tail.clock.NewTimer(timeout)
+	defer stop()
+	go func() {
+		select {
+		case <-timer:
+			wait.Kill(ErrFileTimeout)
+		case <-tail.Dying():
+			wait.Kill(nil)
+		case <-wait.Dying():
 		}
+	}()
+	err := tail.watcher.BlockUntilExists(&wait)
+	if err == tomb.ErrDying && wait.Err() == ErrFileTimeout {
+		err = ErrFileTimeout
 	}
+	wait.Kill(nil)
+	return err
+}
 
-	tail.openReader()
+// maxReadableBackoff caps the retry interval used by WaitForReadable.
+const maxReadableBackoff = 5 * time.Second
+
+func (tail *Tail) close() {
+	if !tail.KeepChannelOpen {
+		close(tail.Lines)
+	}
+	if tail.errs != nil {
+		close(tail.errs)
+	}
+
+	tail.stateLk.Lock()
+	quiesced := tail.quiesced
+	tail.stateLk.Unlock()
+	if !quiesced {
+		tail.closeFile()
+	}
+}
+
+func (tail *Tail) closeFile() {
+	tail.lk.Lock()
+	file := tail.file
+	tail.file = nil
+	tail.lk.Unlock()
+	if file != nil {
+		_ = file.Close()
+	}
+}
+
+func (tail *Tail) reopen() error {
+	// Only the wait for the file to first appear is limited.
+	first := tail.fileIdentifier == ""
+	tail.closeFile()
+	backoff := tail.pollInterval()
+	notRegularSent := false
+	waited := false // for the file to appear
+	attempts := 0
+	reopenBackoff := tail.ReopenBackoff
+	if reopenBackoff <= 0 {
+		reopenBackoff = tail.pollInterval()
+	}
+	// nextAttempt returns how long to wait before trying again, or an
+	// error once MaxReopenAttempts have failed.
+	nextAttempt := func(wait time.Duration, err error) (time.Duration, error) {
+		if first || tail.MaxReopenAttempts <= 0 {
+			return wait, nil
+		}
+		if attempts++; attempts > tail.MaxReopenAttempts {
+			return 0, fmt.Errorf("unable to reopen %s after %d attempts: %s: %w", tail.Filename, tail.MaxReopenAttempts, err, ErrReopenFailed)
+		}
+		wait = reopenBackoff
+		reopenBackoff *= 2
+		return wait, nil
+	}
+	for {
+		err := tail.openFile()
+		if err != nil {
+			if tail.ReOpen && errors.Is(err, ErrNotRegularFile) {
+				if !notRegularSent {
+					tail.send(&Line{Time: tail.clock.Now(), Err: err})
+					notRegularSent = true
+				}
+				wait, err := nextAttempt(tail.pollInterval(), err)
+				if err != nil {
+					return err
+				}
+				select {
+				case <-tail.clock.After(wait):
+				case <-tail.Dying():
+					return tomb.ErrDying
+				}
+				continue
+			}
+			if tail.WaitForReadable && os.IsPermission(err) {
+				tail.Logger.Printf("Waiting for %s to become readable...", tail.Filename)
+				select {
+				case <-tail.clock.After(backoff):
+				case <-tail.Dying():
+					return tomb.ErrDying
+				}
+				if backoff *= 2; backoff > maxReadableBackoff {
+					backoff = maxReadableBackoff
+				}
+				continue
+			}
+			if os.IsNotExist(err) {
+				waited = true
+				tail.Logger.Printf("Waiting for %s to appear...", tail.Filename)
+				timeout := time.Duration(0)
+				if first {
+					timeout = tail.WaitForFileTimeout
+				}
+				timeout, err := nextAttempt(timeout, err)
+				if err != nil {
+					return err
+				}
+				if err := tail.blockUntilExists(timeout); err != nil {
+					if err == tomb.ErrDying {
+						return err
+					}
+					if err == ErrFileTimeout && !first {
+						continue
+					}
+					if err == ErrFileTimeout {
+						return fmt.Errorf("%s did not appear within %v: %w", tail.Filename, timeout, err)
+					}
+					return fmt.Errorf("failed to detect creation of %s: %s", tail.Filename, err)
+				}
+				continue
+			}
+			if errors.Is(err, ErrNotRegularFile) {
+				return err
+			}
+			return fmt.Errorf("unable to open file %s: %s", tail.Filename, err)
+		}
+		break
+	}
+	if first && waited && tail.OnFileAppear != nil {
+		tail.OnFileAppear(tail.Filename)
+	}
+	return nil
+}
+
+// openFile opens the file and computes its identifier.
+func (tail *Tail) openFile() (err error) {
+	// Opening a FIFO would block until it has a writer, so check first.
+	if fi, err := os.Stat(tail.Filename); err == nil && tail.Opener == nil && notRegular(fi.Mode(), tail.Pipe) {
+		return fmt.Errorf("%s is a %v: %w", tail.Filename, fi.Mode().Type(), ErrNotRegularFile)
+	}
+	var file *os.File
+	if tail.Opener != nil {
+		file, tail.fileIdentifier, err = tail.openWithOpener()
+	} else if tail.NonBlockingOpen {
+		file, tail.fileIdentifier, err = openNonBlocking(tail.Filename)
+	} else {
+		file, tail.fileIdentifier, err = OpenFile(tail.Filename)
+	}
+	tail.lk.Lock()
+	tail.file = file
+	tail.lk.Unlock()
+	if err == nil {
+		tail.openedName = tail.Filename
+		if resolved, err := filepath.EvalSymlinks(tail.Filename); err == nil {
+			tail.openedName = resolved
+		}
+	}
+	if err == nil {
+		tail.fileIdentifier = tail.withGeneration(tail.fileIdentifier, tail.file)
+		tail.modTime = time.Time{}
+		tail.noteModTime()
+	}
+	return err
+}
+
+// noteModTime records the modification time of the open file, against which
+// rotated detects a reused inode.
+func (tail *Tail) noteModTime() {
+	if fi, err := tail.file.Stat(); err == nil && fi.ModTime().After(tail.modTime) {
+		tail.modTime = fi.ModTime()
+	}
+}
+
+// withGeneration adds the inode generation of file to its identifier id
+// if UseInodeGeneration is set and the filesystem supports it.
+func (tail *Tail) withGeneration(id string, file *os.File) string {
+	if tail.UseInodeGeneration {
+		if gen, ok := inodeGeneration(file); ok {
+			return fmt.Sprintf("%s:%d", id, gen)
+		}
+	}
+	return id
+}
+
+// openWithOpener opens the file with Config.Opener.
+func (tail *Tail) openWithOpener() (*os.File, string, error) {
+	file, fi, err := tail.Opener(tail.Filename)
+	if err != nil {
+		return nil, "", err
+	}
+	if fi == nil {
+		if fi, err = file.Stat(); err != nil {
+			file.Close()
+			return nil, "", err
+		}
+	}
+	if notRegular(fi.Mode(), tail.Pipe) {
+		file.Close()
+		return nil, "", fmt.Errorf("%s is a %v: %w", tail.Filename, fi.Mode().Type(), ErrNotRegularFile)
+	}
+	fileIdentifier, err := FileIdentifier(file)
+	if err != nil {
+		file.Close()
+		return nil, "", err
+	}
+	return file, fileIdentifier, nil
+}
+
+func (tail *Tail) readLine() ([]byte, int64, bool, error) {
+	if tail.Encoding != UTF8 {
+		return tail.readUTF16Line()
+	}
+	if tail.JSONMode {
+		return tail.readJSONLine()
+	}
+	if tail.TruncateLongLines && tail.MaxLineSize > 0 {
+		return tail.readTruncatedLine()
+	}
+
+	tail.lk.Lock()
+	line, err := tail.reader.ReadBytes(tail.delimiter())
+	tail.lk.Unlock()
+
+	read := int64(len(line))
+	if err != nil {
+		// Note ReadBytes "returns the data read before the error" in
+		// case of an error, including EOF, so we return it as is. The
+		// caller is expected to process it if err is EOF.
+		return line, read, false, err
+	}
+
+	tail.rawRead(line)
+	if !tail.KeepDelimiter {
+		line = tail.trimDelimiter(line)
+	}
+
+	return line, read, false, err
+}
+
+// readNonBlocking reads a line like readLine, prepending the start of it
+// kept from an earlier read of a pipe. With NonBlockingOpen, a read finding
+// no data gives up after a short while, which is reported as EOF.
+func (tail *Tail) readNonBlocking() ([]byte, int64, bool, error) {
+	if tail.NonBlockingOpen && tail.file != nil {
+		// Regular files do not support deadlines; their reads never block.
+		tail.file.SetReadDeadline(time.Now().Add(nonBlockingReadTimeout))
+	}
+	line, read, truncated, err := tail.readLine()
+	if tail.NonBlockingOpen && errors.Is(err, os.ErrDeadlineExceeded) {
+		err = io.EOF
+	}
+	if tail.Pipe && tail.Follow && err == io.EOF && len(line) > 0 {
+		// The start of the line is kept in pending rather than read
+		// again, so it is passed on as it is read.
+		tail.rawRead(line)
+	}
+	if len(tail.pending) > 0 {
+		read += int64(len(tail.pending))
+		line = append(tail.pending, line...)
+		tail.pending = nil
+	}
+	return line, read, truncated, err
+}
+
+// trimDelimiter removes the delimiter ending line, and with TrimCR a
+// carriage return before it.
+func (tail *Tail) trimDelimiter(line []byte) []byte {
+	line = line[:len(line)-1]
+	if tail.TrimCR {
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+	}
+	return line
+}
+
+// delimiter returns the byte that ends a line.
+func (tail *Tail) delimiter() byte {
+	if tail.Delimiter == "" {
+		return '\n'
+	}
+	return tail.Delimiter[0]
+}
+
+// readTruncatedLine reads a line like readLine, but keeps at most
+// MaxLineSize bytes of it, reporting whether the rest was discarded.
+// The number of bytes read includes the discarded ones.
+func (tail *Tail) readTruncatedLine() ([]byte, int64, bool, error) {
+	var buf, raw []byte
+	var read int64
+	var err error
+
+	tail.lk.Lock()
+	for {
+		var chunk []byte
+		chunk, err = tail.reader.ReadSlice(tail.delimiter())
+		read += int64(len(chunk))
+		if tail.OnRawRead != nil {
+			raw = append(raw, chunk...)
+		}
+		// Keep one byte past the limit to tell whether it was exceeded.
+		if room := tail.MaxLineSize + 1 - len(buf); room > 0 {
+			if room > len(chunk) {
+				room = len(chunk)
+			}
+			buf = append(buf, chunk[:room]...)
+		}
+		if err != bufio.ErrBufferFull {
+			break
+		}
+	}
+	tail.lk.Unlock()
+
+	line := buf
+	delimited := err == nil
+	if delimited {
+		tail.rawRead(raw)
+		if int64(len(line)) == read && !tail.KeepDelimiter {
+			line = tail.trimDelimiter(line)
+		} else {
+			line = bytes.TrimSuffix(line, []byte{tail.delimiter()})
+		}
+	}
+	truncated := len(line) > tail.MaxLineSize
+	if truncated {
+		line = line[:tail.MaxLineSize]
+	}
+	if delimited && tail.KeepDelimiter {
+		line = append(line, tail.delimiter())
+	}
+	return line, read, truncated, err
+}
+
+func (tail *Tail) tailFileSync() {
+	defer tail.Done()
+	defer tail.close()
+	defer tail.checkpoint()
+	defer tail.sendPartialOnStop()
+
+	if !tail.openSync() {
+		return
+	}
+	if tail.gzipReplay() {
+		if err := tail.openGzip(); err != nil {
+			tail.Kill(err)
+			return
+		}
+		span := tail.startReplay(tail.Filename)
+		defer span.End()
+	}
+	if !tail.catchUpRotated() {
+		return
+	}
+
+	tail.openReader()
 
 	// Read line by line.
 	for {
-		line, numRead, err := tail.readLine()
+		tail.seeked = false
+		select {
+		case req := <-tail.reloads:
+			tail.serveReload(req)
+		default:
+		}
+		if !tail.waitWhilePaused() {
+			return
+		}
+		line, numRead, truncated, err := tail.readNonBlocking()
+
+		if err != io.EOF && err != nil {
+			if tail.truncatedGzip(line, err) {
+				return
+			}
+			// non-EOF error; any partial line read is discarded and the
+			// reported offset stays at the last complete line.
+			tail.flushRepeat()
+			err = &ReadError{Filename: tail.Filename, Offset: tail.offset, Err: err}
+			tail.send(&Line{Time: tail.clock.Now(), Err: err, Offset: tail.offset, FileIdentifier: tail.fileIdentifier})
+			if !tail.retryRead() {
+				tail.Kill(err)
+				return
+			}
+			continue
+		}
+		if tail.readErrors > 0 {
+			if tail.EmitRecoveryMarkers {
+				tail.send(&Line{Time: tail.clock.Now(), Offset: tail.offset, FileIdentifier: tail.fileIdentifier, Recovered: true, ErrorCount: tail.readErrors})
+			}
+			tail.readErrors = 0
+		}
 
 		// Process `line` even if err is EOF.
+		tail.atEOF.Store(err == io.EOF && len(line) == 0)
 		if err == nil {
-			offset += numRead
-			cooloff := !tail.sendLine(line, offset)
+			cooloff, ok := tail.sendRead(line, numRead, truncated)
+			if !ok {
+				return
+			}
 			if cooloff {
 				// Wait a second before seeking till the end of
 				// file when rate limit is reached.
 				msg := "too much log activity; waiting a second " +
 					"before resuming tailing"
-				tail.Lines <- &Line{Text: msg, Time: time.Now(), Err: errors.New(msg)}
+				tail.send(&Line{Text: msg, Time: tail.clock.Now(), Err: errors.New(msg)})
 				select {
-				case <-time.After(time.Second):
+				case <-tail.clock.After(time.Second):
 				case <-tail.Dying():
 					return
 				}
@@ -288,19 +1466,30 @@ func (tail *Tail) tailFileSync() {
 				}
 			}
 		} else if err == io.EOF {
+			tail.flushRepeat()
 			if !tail.Follow {
-				offset += numRead
-				if line != "" {
-					tail.sendLine(line, offset)
+				// A final line without a delimiter is still delivered,
+				// but its offset stays at the start of the line so a
+				// resume re-reads it once it is complete.
+				if len(line) > 0 {
+					tail.sendFinal(line, truncated)
 				}
 				return
 			}
 
+			tail.eofOffset = tail.offset + numRead
+
+			// A pipe cannot be rewound, so keep the start of the line
+			// until the rest of it is read.
+			if tail.Pipe && len(line) > 0 {
+				tail.pending = line
+			}
+
 			// Try to rewind back to the end of the last full line if we read a partial line
-			if tail.Follow && line != "" && !tail.Pipe {
+			if tail.Follow && len(line) > 0 && !tail.Pipe {
 				// this has the potential to never return the last line if
 				// it's not followed by a newline; seems a fair trade here
-				err := tail.seekTo(SeekInfo{Offset: offset, Whence: 0})
+				err := tail.seekTo(SeekInfo{Offset: tail.offset, Whence: 0})
 				if err != nil {
 					tail.Kill(err)
 					return
@@ -317,10 +1506,6 @@ func (tail *Tail) tailFileSync() {
 				}
 				return
 			}
-		} else {
-			// non-EOF error
-			_ = tail.Killf("Error reading %s: %s", tail.Filename, err)
-			return
 		}
 
 		select {
@@ -334,62 +1519,522 @@ func (tail *Tail) tailFileSync() {
 	}
 }
 
+// openSync performs the deferred first open of the file and seeks to the
+// requested location. It returns false if the tail has been killed.
+func (tail *Tail) openSync() bool {
+	span := tail.startSpan(SpanOpen)
+	defer span.End()
+
+	if tail.file == nil && tail.source == nil {
+		// deferred first open.
+		err := tail.reopen()
+		if err != nil {
+			span.SetAttr(AttrError, err.Error())
+			if err != tomb.ErrDying {
+				tail.Kill(err)
+			}
+			return false
+		}
+	}
+	span.SetAttr(AttrFileIdentifier, tail.fileIdentifier)
+
+	// Seek to requested location on first open of the file.
+	if tail.Location != nil {
+		if tail.Location.FileIdentifier == "" || tail.Location.FileIdentifier == tail.fileIdentifier {
+			pos, err := tail.resolveSeek(*tail.Location)
+			if err == nil && tail.staleLocation(pos.Offset) {
+				tail.logEvent("truncate", nil, "%s was truncated since offset %d was saved; reading it from the start", tail.Filename, pos.Offset)
+				pos.Offset = 0
+			}
+			if err == nil && tail.AlignToLine && tail.file != nil && !tail.Pipe && tail.gz == nil {
+				pos.Offset, err = tail.lineStart(pos.Offset, 1)
+			}
+			if err == nil {
+				pos.Offset, err = tail.includeLine(pos)
+			}
+			var seeker io.Seeker
+			if err == nil {
+				seeker, err = tail.seeker()
+			}
+			if err == nil {
+				_, err = seeker.Seek(pos.Offset, io.SeekStart)
+			}
+			tail.Logger.Printf("Seeked %s - %+v\n", tail.Filename, tail.Location)
+			if err != nil {
+				span.SetAttr(AttrError, err.Error())
+				_ = tail.Killf("Seek error on %s: %s", tail.Filename, err)
+				return false
+			}
+			tail.offset = pos.Offset
+		} else {
+			tail.Logger.Printf("Skipping seek because fileIdentifier %q does not match requested FileIdentifier %q", tail.fileIdentifier, tail.Location.FileIdentifier)
+		}
+	}
+	if tail.LastNLines > 0 && tail.Location == nil && tail.file != nil && tail.source == nil && !tail.Pipe && !tail.gzipReplay() {
+		offset, err := tail.lastLinesStart(tail.LastNLines)
+		if err == nil {
+			_, err = tail.file.Seek(offset, io.SeekStart)
+		}
+		if err != nil {
+			span.SetAttr(AttrError, err.Error())
+			_ = tail.Killf("Seek error on %s: %s", tail.Filename, err)
+			return false
+		}
+		tail.offset = offset
+		tail.Logger.Printf("Seeked %s to the last %d lines at offset %d", tail.Filename, tail.LastNLines, offset)
+	}
+	if tail.SeekTime != nil && tail.TimeParser != nil {
+		if err := tail.seekToTime(*tail.SeekTime); err != nil {
+			span.SetAttr(AttrError, err.Error())
+			tail.Kill(err)
+			return false
+		}
+	}
+	span.SetAttr(AttrOffset, tail.offset)
+	return true
+}
+
+// maxReadRetries is the number of consecutive read errors retried with ReOpen.
+const maxReadRetries = 5
+
+// nonBlockingReadTimeout bounds a read with NonBlockingOpen.
+const nonBlockingReadTimeout = 10 * time.Millisecond
+
+// retryRead reopens the file after a read error and resumes from the last
+// complete line. It returns false if the error should not be retried.
+func (tail *Tail) retryRead() bool {
+	if !tail.ReOpen || tail.readErrors >= maxReadRetries {
+		return false
+	}
+	tail.readErrors++
+
+	select {
+	case <-tail.clock.After(tail.pollInterval()):
+	case <-tail.Dying():
+		return false
+	}
+
+	oldIdentifier := tail.fileIdentifier
+	if err := tail.reopen(); err != nil {
+		return false
+	}
+	if tail.fileIdentifier == oldIdentifier {
+		if _, err := tail.file.Seek(tail.offset, io.SeekStart); err != nil {
+			return false
+		}
+	} else {
+		tail.offset = 0
+		tail.resetNum()
+	}
+	tail.changes = nil
+	tail.openReader()
+	return true
+}
+
+// Watcher kinds reported by WatcherKind.
+const (
+	WatcherInotify = "inotify"
+	WatcherPolling = "polling"
+	WatcherCustom  = "custom" // Config.Watcher
+)
+
+// newInotifyWatcher creates the watcher used unless Poll is set. Tests
+// replace it to simulate inotify failures.
+var newInotifyWatcher = func(filename string) watch.FileWatcher {
+	return watch.NewInotifyFileWatcher(filename)
+}
+
+// WatcherKind returns the kind of watcher currently in use, WatcherInotify,
+// WatcherPolling or WatcherCustom. It changes to WatcherPolling if inotify
+// fails while tailing.
+func (tail *Tail) WatcherKind() string {
+	tail.stateLk.Lock()
+	defer tail.stateLk.Unlock()
+	if tail.newest != nil {
+		return tail.newest.WatcherKind()
+	}
+	if _, ok := tail.watcher.(*watch.PollingFileWatcher); ok || (tail.watcher == nil && tail.Poll) {
+		return WatcherPolling
+	}
+	if tail.Watcher != nil {
+		return WatcherCustom
+	}
+	return WatcherInotify
+}
+
 // waitForChanges waits until the file has been appended, deleted,
 // moved or truncated. When moved or deleted - the file will be
 // reopened if ReOpen is true. Truncated files are always reopened.
 func (tail *Tail) waitForChanges() error {
 	if tail.changes == nil {
-		pos, err := tail.file.Seek(0, io.SeekCurrent)
-		if err != nil {
-			return err
+		// The file may have been moved before we got to watch it, in
+		// which case the watcher would follow its replacement.
+		if tail.rotated() {
+			return tail.handleDeleted()
+		}
+
+		// A pipe has no position nor size to compare it with.
+		var pos int64
+		if !tail.Pipe {
+			cur, err := tail.file.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+			pos = cur
 		}
+		var err error
 		tail.changes, err = tail.watcher.ChangeEvents(&tail.Tomb, pos)
+		if err != nil && !os.IsNotExist(err) && tail.WatcherKind() == WatcherInotify {
+			// inotify can fail for lack of instances or watches
+			// (EMFILE, ENOSPC) or on filesystems that don't support
+			// it; polling works everywhere.
+			tail.logEvent("watcher_error", err, "Falling back to polling for %s: %s", tail.Filename, err)
+			tail.stateLk.Lock()
+			tail.watcher = tail.newPollingWatcher()
+			tail.stateLk.Unlock()
+			tail.changes, err = tail.watcher.ChangeEvents(&tail.Tomb, pos)
+		}
 		if err != nil {
+			if os.IsNotExist(err) {
+				return tail.handleDeleted()
+			}
 			return err
 		}
+
+		// Check again in case the watch was added to the replacement.
+		if tail.rotated() {
+			return tail.handleDeleted()
+		}
+	}
+
+	var idle <-chan time.Time
+	if tail.IdleTimeout > 0 && !tail.ReOpen {
+		timer, stop := tail.clock.NewTimer(tail.IdleTimeout)
+		defer stop()
+		idle = timer
+	}
+
+	var resetCheck <-chan time.Time
+	resetInterval := tail.pollInterval()
+	stopReset := func() bool { return false }
+	defer func() { stopReset() }()
+	if tail.DetectInPlaceReset {
+		if tail.resetInPlace() {
+			return tail.handleTruncated()
+		}
+		resetCheck, stopReset = tail.clock.NewTimer(resetInterval)
+	}
+
+	for {
+		select {
+		case <-idle:
+			return fmt.Errorf("%s not modified for %v: %w", tail.Filename, tail.IdleTimeout, ErrIdleTimeout)
+		case req := <-tail.seeks:
+			tail.serveSeek(req)
+			return nil
+		case req := <-tail.reloads:
+			tail.serveReload(req)
+		case <-resetCheck:
+			if tail.resetInPlace() {
+				return tail.handleTruncated()
+			}
+			resetCheck, stopReset = tail.clock.NewTimer(resetInterval)
+		case <-tail.changes.Modified:
+			tail.coalesceModified()
+			tail.noteModTime()
+			if tail.overwritten() || tail.resetInPlace() {
+				return tail.handleTruncated()
+			}
+			return nil
+		case <-tail.changes.Deleted:
+			tail.changes = nil
+			if !tail.rotated() {
+				// The event is for a file we have already moved on from.
+				return nil
+			}
+			return tail.handleDeleted()
+		case <-tail.changes.Truncated:
+			// The watcher compares sizes by name, so a file that has been
+			// replaced or a stale size can look like a truncation.
+			if tail.rotated() {
+				return tail.handleDeleted()
+			}
+			if fi, err := tail.file.Stat(); err == nil && fi.Size() >= tail.eofOffset && !tail.overwritten() {
+				return nil
+			}
+			return tail.handleTruncated()
+		case <-tail.Dying():
+			if tail.Err() == errStopAtEOF && tail.grownSinceDrain() {
+				// Data may have been appended before the change event
+				// arrived; read it before stopping.
+				return nil
+			}
+			return ErrStop
+		}
 	}
+}
 
+// coalesceModified waits for EventCoalesceWindow after a modification and
+// discards the modifications notified meanwhile, since the read that
+// follows picks up their data.
+func (tail *Tail) coalesceModified() {
+	if tail.EventCoalesceWindow <= 0 {
+		return
+	}
+	timer, stop := tail.clock.NewTimer(tail.EventCoalesceWindow)
+	defer stop()
+	select {
+	case <-timer:
+	case <-tail.Dying():
+	}
 	select {
 	case <-tail.changes.Modified:
-		return nil
-	case <-tail.changes.Deleted:
-		tail.changes = nil
-		if tail.ReOpen {
-			// XXX: we must not log from a library.
-			tail.Logger.Printf("Re-opening moved/deleted file %s ...", tail.Filename)
-			if err := tail.reopen(); err != nil {
-				return err
+	default:
+	}
+}
+
+// handleTruncated reopens the file after it was truncated and reads it from
+// the start.
+func (tail *Tail) handleTruncated() error {
+	// Always reopen truncated files (Follow is true)
+	if !tail.drainCompressedRotation() {
+		return ErrStop
+	}
+	tail.logEvent("truncate", nil, "Re-opening truncated file %s ...", tail.Filename)
+	oldIdentifier := tail.fileIdentifier
+	oldSize := tail.eofOffset
+	if err := tail.tracedReopen(SpanTruncate); err != nil {
+		return err
+	}
+	tail.logEvent("reopened", nil, "Successfully reopened truncated %s", tail.Filename)
+	tail.stats.truncations.Add(1)
+	tail.notifyTruncate(oldSize)
+	tail.notifyReopen(oldIdentifier)
+	tail.offset = 0
+	tail.resetNum()
+	tail.openReader()
+	return nil
+}
+
+// overwritten reports whether the file was truncated and written again
+// before the truncation was noticed, so that its size alone does not show
+// it. The last line read must still end where it did.
+func (tail *Tail) overwritten() bool {
+	if !tail.lineEnd || tail.offset == 0 || tail.Pipe || tail.gz != nil {
+		return false
+	}
+	return !tail.delimiterBefore(tail.offset)
+}
+
+// staleLocation reports whether a Location saved with the identifier of the
+// file no longer falls at the end of a line of it, because the file was
+// truncated, and possibly written again, since.
+func (tail *Tail) staleLocation(offset int64) bool {
+	loc := tail.Location
+	if loc.FileIdentifier == "" || loc.Whence != io.SeekStart || offset == 0 || tail.file == nil || tail.Pipe || tail.gz != nil {
+		return false
+	}
+	return !tail.delimiterBefore(offset)
+}
+
+// delimiterBefore reports whether the byte before offset in the file is the
+// delimiter, as it is at the end of a line. It is false if the file ends
+// before offset, and true if it cannot be read.
+func (tail *Tail) delimiterBefore(offset int64) bool {
+	want := tail.delimiter()
+	if tail.Encoding == UTF16LE {
+		want = 0 // high byte of the delimiter's code unit
+	}
+	b := make([]byte, 1)
+	if _, err := tail.file.ReadAt(b, offset-1); err != nil {
+		return err != io.EOF
+	}
+	return b[0] == want
+}
+
+// delimiterUnit returns the delimiter as encoded in the file.
+func (tail *Tail) delimiterUnit() []byte {
+	switch tail.Encoding {
+	case UTF16LE:
+		return []byte{tail.delimiter(), 0}
+	case UTF16BE:
+		return []byte{0, tail.delimiter()}
+	}
+	return []byte{tail.delimiter()}
+}
+
+// lastLinesStart returns the offset of the start of the last n lines of
+// the file, or 0 if it has no more than n. A final line without a
+// delimiter counts as a line.
+func (tail *Tail) lastLinesStart(n int) (int64, error) {
+	fi, err := tail.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	// The delimiter at the end of the file ends the last line.
+	return tail.lineStart(fi.Size()-int64(len(tail.delimiterUnit())), n)
+}
+
+// includeLine returns the offset at which to start reading for pos, whose
+// Offset is relative to the start of the file: that of the line ending at
+// pos.Offset if pos is Inclusive.
+func (tail *Tail) includeLine(pos SeekInfo) (int64, error) {
+	if !pos.Inclusive || pos.Offset == 0 || tail.file == nil || tail.source != nil || tail.Pipe || tail.gz != nil {
+		return pos.Offset, nil
+	}
+	start, err := tail.lineStart(pos.Offset, 1)
+	if err != nil || start != pos.Offset {
+		// Not at the end of a line.
+		return pos.Offset, err
+	}
+	return tail.lineStart(pos.Offset-1, 1)
+}
+
+// lineStart returns the offset of the start of the nth line back from
+// offset: just past the nth delimiter that ends at or before it, or 0 if
+// there are fewer. For n of 1, this is the start of the line that offset
+// falls in, which is offset itself at the end of a line. An offset past the
+// end of the file is taken as the end of the file.
+func (tail *Tail) lineStart(offset int64, n int) (int64, error) {
+	delim := tail.delimiterUnit()
+	unit := int64(len(delim))
+
+	fi, err := tail.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if offset > fi.Size() {
+		offset = fi.Size()
+	}
+	offset -= offset % unit
+
+	buf := make([]byte, 4096)
+	for offset > 0 {
+		chunkLen := int64(len(buf))
+		if chunkLen > offset {
+			chunkLen = offset
+		}
+		chunk := buf[:chunkLen]
+		if _, err := tail.file.ReadAt(chunk, offset-chunkLen); err != nil {
+			return 0, err
+		}
+		for i := chunkLen; i >= unit; i -= unit {
+			if !bytes.Equal(chunk[i-unit:i], delim) {
+				continue
 			}
-			tail.Logger.Printf("Successfully reopened %s", tail.Filename)
-			tail.openReader()
-			return nil
-		} else {
-			tail.Logger.Printf("Stopping tail as file no longer exists: %s", tail.Filename)
+			if n--; n == 0 {
+				return offset - chunkLen + i, nil
+			}
+		}
+		offset -= chunkLen
+	}
+	return 0, nil
+}
+
+// notifyTruncate calls Config.OnTruncate after the truncated file has been
+// reopened.
+func (tail *Tail) notifyTruncate(oldSize int64) {
+	if tail.OnTruncate == nil {
+		return
+	}
+	var newSize int64
+	if fi, err := tail.file.Stat(); err == nil {
+		newSize = fi.Size()
+	}
+	tail.OnTruncate(oldSize, newSize)
+}
+
+// notifyReopen calls Config.OnReopen after the file has been reopened.
+func (tail *Tail) notifyReopen(oldIdentifier string) {
+	if tail.OnReopen != nil {
+		tail.OnReopen(oldIdentifier, tail.fileIdentifier)
+	}
+}
+
+// handleDeleted reopens the file after it was moved or deleted if ReOpen is
+// set, and stops the tail otherwise. Anything written to the old file since
+// EOF was reached is read first.
+func (tail *Tail) handleDeleted() error {
+	if fi, err := tail.file.Stat(); err == nil && fi.Size() > tail.eofOffset {
+		return nil
+	}
+
+	if tail.ReOpen {
+		if !tail.drainCompressedRotation() {
 			return ErrStop
 		}
-	case <-tail.changes.Truncated:
-		// Always reopen truncated files (Follow is true)
-		tail.Logger.Printf("Re-opening truncated file %s ...", tail.Filename)
-		if err := tail.reopen(); err != nil {
+		// XXX: we must not log from a library.
+		tail.logEvent("rotate", nil, "Re-opening moved/deleted file %s ...", tail.Filename)
+		oldIdentifier := tail.fileIdentifier
+		if err := tail.tracedReopen(SpanRotate); err != nil {
 			return err
 		}
-		tail.Logger.Printf("Successfully reopened truncated %s", tail.Filename)
+		tail.logEvent("reopened", nil, "Successfully reopened %s", tail.Filename)
+		tail.stats.reopens.Add(1)
+		tail.notifyReopen(oldIdentifier)
+		tail.offset = 0
+		tail.resetNum()
 		tail.openReader()
 		return nil
-	case <-tail.Dying():
+	} else {
+		tail.logEvent("deleted", nil, "Stopping tail as file no longer exists: %s", tail.Filename)
 		return ErrStop
 	}
 }
 
+// rotated reports whether tail.Filename no longer refers to the open file.
+func (tail *Tail) rotated() bool {
+	if tail.Pipe {
+		return false
+	}
+	fi, err := os.Stat(tail.Filename)
+	if err != nil {
+		// Windows denies access to a deleted file while handles to it are open.
+		return os.IsNotExist(err) || (runtime.GOOS == "windows" && os.IsPermission(err))
+	}
+	cur, err := tail.file.Stat()
+	if err != nil {
+		return false
+	}
+	// A modification time going backwards means the inode was reused by
+	// a new file, as the polling watcher also reports.
+	return !os.SameFile(fi, cur) || fi.ModTime().Before(tail.modTime)
+}
+
+// grownSinceDrain reports whether the file size differs from the size seen on
+// the previous call, so StopAtEOF reads everything written before it was
+// requested without looping on a trailing partial line.
+func (tail *Tail) grownSinceDrain() bool {
+	fi, err := tail.file.Stat()
+	if err != nil || fi.Size() == tail.drainedSize {
+		return false
+	}
+	tail.drainedSize = fi.Size()
+	return true
+}
+
 func (tail *Tail) openReader() {
 	tail.lk.Lock()
+	tail.reader = tail.newReader(tail.fileReader())
+	tail.lk.Unlock()
+	tail.setPosition(tail.offset)
+	if tail.offset == 0 {
+		tail.skipLeft = tail.SkipLines
+	}
+}
+
+// newReader buffers r with ReadBufferSize, or more if needed to hold a line
+// of MaxLineSize.
+func (tail *Tail) newReader(r io.Reader) *bufio.Reader {
+	size := 4096 // bufio's default
 	if tail.MaxLineSize > 0 {
 		// add 2 to account for newline characters
-		tail.reader = bufio.NewReaderSize(tail.file, tail.MaxLineSize+2)
-	} else {
-		tail.reader = bufio.NewReader(tail.file)
+		size = tail.MaxLineSize + 2
 	}
-	tail.lk.Unlock()
+	if tail.ReadBufferSize > size {
+		size = tail.ReadBufferSize
+	}
+	return bufio.NewReaderSize(r, size)
 }
 
 func (tail *Tail) seekEnd() error {
@@ -397,29 +2042,243 @@ func (tail *Tail) seekEnd() error {
 }
 
 func (tail *Tail) seekTo(pos SeekInfo) error {
-	_, err := tail.file.Seek(pos.Offset, pos.Whence)
+	seeker, err := tail.seeker()
+	if err != nil {
+		return err
+	}
+	offset, err := seeker.Seek(pos.Offset, pos.Whence)
 	if err != nil {
 		return fmt.Errorf("seek error on %s: %s", tail.Filename, err)
 	}
+	tail.offset = offset
 	// Reset the read buffer whenever the file is re-seek'ed
-	tail.reader.Reset(tail.file)
+	tail.reader.Reset(tail.fileReader())
 	return nil
 }
 
+// errNotSeeker is returned when seeking in a reader that cannot.
+var errNotSeeker = errors.New("tail: reader is not an io.Seeker")
+
+// seeker returns what fileReader reads from, for seeking, or errNotSeeker
+// if that is a reader that is not an io.Seeker.
+func (tail *Tail) seeker() (io.Seeker, error) {
+	if tail.source != nil {
+		s, ok := tail.source.(io.Seeker)
+		if !ok {
+			return nil, errNotSeeker
+		}
+		return s, nil
+	}
+	return tail.file, nil
+}
+
+// fileReader returns the reader lines are read from.
+func (tail *Tail) fileReader() io.Reader {
+	var r io.Reader = tail.file
+	if tail.source != nil {
+		r = tail.source
+	}
+	if tail.gz != nil {
+		r = tail.gz
+	}
+	return r
+}
+
+// rawRead passes consumed bytes to Config.OnRawRead.
+func (tail *Tail) rawRead(chunk []byte) {
+	if tail.OnRawRead != nil {
+		tail.OnRawRead(chunk)
+	}
+}
+
+// resetNum restarts Line.Num for the lines that follow.
+func (tail *Tail) resetNum() {
+	tail.num = 0
+	tail.resetPending = true
+}
+
+// send sends line to Lines, dropping a line instead of blocking if Lines
+// is full and OverflowPolicy says so. It returns false if tailing was
+// stopped while waiting for the consumer, in which case line is dropped.
+func (tail *Tail) send(line *Line) bool {
+	if line.Err != nil && tail.reportError(line.Err) {
+		tail.stats.errors.Add(1)
+		return true
+	}
+	if line.Filename == "" {
+		line.Filename = tail.openedName
+	}
+	if line.Tag == "" {
+		line.Tag = tail.Name
+	}
+	if line.Err != nil {
+		tail.stats.errors.Add(1)
+	}
+	switch tail.OverflowPolicy {
+	case DropNewest:
+		select {
+		case tail.Lines <- line:
+		default:
+			tail.dropped.Add(1)
+		}
+		return true
+	case DropOldest:
+		select {
+		case tail.Lines <- line:
+			return true
+		default:
+		}
+		// Only this goroutine sends, Validate ruling out a shared
+		// Channel, so once a line has been taken off there is room for
+		// the new one.
+		select {
+		case <-tail.Lines:
+			tail.dropped.Add(1)
+		default:
+		}
+	}
+	dying := tail.Dying()
+	var giveUp <-chan time.Time
+	for {
+		select {
+		case tail.Lines <- line:
+			return true
+		case req := <-tail.seeks:
+			// line was read before the seek and is dropped.
+			tail.serveSeek(req)
+			return true
+		case req := <-tail.reloads:
+			tail.serveReload(req)
+		case <-dying:
+			dying = nil
+			if tail.Err() == errStopAtEOF {
+				// The lines up to EOF are still to be received.
+				continue
+			}
+			if !tail.final {
+				return false
+			}
+			// The final partial line is given a moment to be received,
+			// but a consumer that stopped reading must not hold up Stop.
+			giveUp = tail.clock.After(finalSendTimeout)
+		case <-giveUp:
+			return false
+		}
+	}
+}
+
+// finalSendTimeout bounds the wait for the final line of EmitPartialOnStop
+// to be received once tailing is stopped.
+const finalSendTimeout = time.Second
+
+// errorsBuffer is the capacity of Tail.Errors.
+const errorsBuffer = 16
+
+// reportError sends a non-fatal error on Errors, if SeparateErrors is set.
+// It returns false if it is not.
+func (tail *Tail) reportError(err error) bool {
+	if tail.errs == nil {
+		return false
+	}
+	select {
+	case tail.errs <- err:
+	default:
+	}
+	return true
+}
+
+// Dropped returns the number of lines dropped because Lines was full.
+func (tail *Tail) Dropped() uint64 {
+	tail.stateLk.Lock()
+	defer tail.stateLk.Unlock()
+	dropped := tail.dropped.Load()
+	if tail.newest != nil {
+		dropped += tail.newest.Dropped()
+	}
+	return dropped
+}
+
+// pace waits until the next line may be sent under RateLimit. It returns
+// false if tailing is stopped while waiting.
+func (tail *Tail) pace() bool {
+	if tail.RateLimit <= 0 {
+		return true
+	}
+	now := tail.clock.Now()
+	if tail.nextSend.Before(now) {
+		tail.nextSend = now
+	}
+	wait := tail.nextSend.Sub(now)
+	tail.nextSend = tail.nextSend.Add(time.Duration(float64(time.Second) / tail.RateLimit))
+	if wait <= 0 {
+		return true
+	}
+
+	timer, stop := tail.clock.NewTimer(wait)
+	defer stop()
+	select {
+	case <-timer:
+		return true
+	case <-tail.Dying():
+		if tail.Err() == errStopAtEOF {
+			<-timer
+			return true
+		}
+		return false
+	}
+}
+
 // sendLine sends the line(s) to Lines channel, splitting longer lines
 // if necessary. Return false if rate limit is reached.
-func (tail *Tail) sendLine(line string, offset int64) bool {
-	now := time.Now()
-	lines := []string{line}
+func (tail *Tail) sendLine(line []byte, offset int64, truncated bool) bool {
+	tail.stats.linesRead.Add(1)
+	if tail.skipLeft > 0 {
+		tail.skipLeft--
+		tail.num++
+		tail.recordPosition(offset)
+		return true
+	}
+	now := tail.clock.Now()
+	lines := [][]byte{line}
 
 	// Split longer lines
-	if tail.MaxLineSize > 0 && len(line) > tail.MaxLineSize {
-		lines = util.PartitionString(line, tail.MaxLineSize)
+	if tail.MaxLineSize > 0 && len(line) > tail.MaxLineSize && !tail.TruncateLongLines {
+		lines = util.PartitionBytes(line, tail.MaxLineSize)
 	}
 
 	for _, line := range lines {
+		tail.num++
+		if tail.MatchRegexp != nil && !tail.MatchRegexp.Match(line) {
+			continue
+		}
 		// TODO offset
-		tail.Lines <- &Line{Text: line, Time: now, Err: nil, FileIdentifier: tail.fileIdentifier, Offset: offset}
+		l := &Line{Bytes: line, Time: now, Err: nil, FileIdentifier: tail.fileIdentifier, Offset: offset, Num: tail.num, Reset: tail.resetPending, Truncated: truncated, Partial: tail.partial}
+		if !tail.OmitText && tail.StripANSI {
+			l.Text = string(stripANSI(line))
+		} else if !tail.OmitText {
+			l.Text = string(line)
+		}
+		if tail.LineFilter != nil && !tail.LineFilter(l) {
+			continue
+		}
+		if tail.SuppressRepeats {
+			tail.holdRepeat(l)
+		} else {
+			if !tail.pace() || !tail.send(l) {
+				return true
+			}
+		}
+		if tail.seeked {
+			// The rest of the line is from before the seek.
+			return true
+		}
+		tail.resetPending = false
+	}
+	if tail.ResetOnMatch != nil && tail.ResetOnMatch.Match(line) {
+		tail.resetNum()
+	}
+	if tail.held == nil {
+		tail.recordPosition(offset)
 	}
 
 	if tail.Config.RateLimiter != nil {
@@ -434,9 +2293,88 @@ func (tail *Tail) sendLine(line string, offset int64) bool {
 	return true
 }
 
+// sendRead counts a complete line of numRead bytes against MaxBytes and
+// sends it. It returns false once MaxBytes has been reached, and cooloff if
+// RateLimiter is full.
+func (tail *Tail) sendRead(line []byte, numRead int64, truncated bool) (cooloff, ok bool) {
+	if tail.MaxBytes > 0 && tail.bytesRead+numRead > tail.MaxBytes {
+		tail.stopAtByteLimit(line)
+		return false, false
+	}
+	tail.offset += numRead
+	tail.lineEnd = true
+	tail.bytesRead += numRead
+	tail.stats.bytesRead.Add(uint64(numRead))
+	cooloff = !tail.sendLine(line, tail.offset, truncated)
+	if tail.MaxBytes > 0 && tail.bytesRead >= tail.MaxBytes {
+		tail.stopAtByteLimit(nil)
+		return cooloff, false
+	}
+	return cooloff, true
+}
+
+// sendFinal sends the final line without a delimiter of a file that is
+// complete, counting it against MaxBytes. It returns false once MaxBytes
+// has been reached.
+func (tail *Tail) sendFinal(line []byte, truncated bool) bool {
+	tail.rawRead(line)
+	if tail.MaxBytes > 0 && tail.bytesRead+int64(len(line)) > tail.MaxBytes {
+		tail.stopAtByteLimit(line)
+		return false
+	}
+	tail.bytesRead += int64(len(line))
+	tail.sendPartial(line, truncated)
+	return true
+}
+
+// sendPartial sends a final line that has no delimiter.
+func (tail *Tail) sendPartial(line []byte, truncated bool) {
+	tail.partial = true
+	tail.sendLine(line, tail.offset, truncated)
+	tail.partial = false
+	tail.flushRepeat()
+}
+
+// sendPartialOnStop sends, with EmitPartialOnStop, the line without a
+// delimiter, if any, that follows the last complete line when tailing
+// stops, however it was stopped, unless it failed.
+func (tail *Tail) sendPartialOnStop() {
+	if !tail.EmitPartialOnStop || !tail.Follow || tail.reader == nil {
+		return
+	}
+	if err := tail.Err(); err != tomb.ErrStillAlive && err != nil && err != errStopAtEOF {
+		return
+	}
+	// Nothing past MaxBytes is sent.
+	if tail.Pipe || tail.gz != nil || tail.StopReason() == ByteLimit {
+		return
+	}
+	line, _, truncated, err := tail.readLine()
+	// A line completed in the meantime is left for a resume to read.
+	if err == io.EOF && len(line) > 0 {
+		tail.rawRead(line)
+		tail.final = true
+		tail.sendPartial(line, truncated)
+		tail.final = false
+	}
+}
+
 // Cleanup removes inotify watches added by the tail package. This function is
 // meant to be invoked from a process's exit handler. Linux kernel may not
 // automatically remove inotify watches after the process exits.
+//
+// After Quiesce, Cleanup also closes the file that was kept open.
 func (tail *Tail) Cleanup() {
-	_ = watch.Cleanup(tail.Filename)
+	tail.stateLk.Lock()
+	watched := tail.watcher != nil
+	quiesced := tail.quiesced
+	tail.stateLk.Unlock()
+
+	if watched {
+		_ = watch.Cleanup(tail.Filename)
+	}
+	if quiesced {
+		_ = tail.Wait()
+		tail.closeOnce.Do(tail.closeFile)
+	}
 }
@@ -14,14 +14,54 @@ func OpenFile(name string) (file *os.File, fileIdentifier string, err error) {
 		return nil, "", err
 	}
 
-	fileInfo, err := file.Stat()
+	fileIdentifier, err = FileIdentifier(file)
 	if err != nil {
+		file.Close()
 		return nil, "", err
 	}
+	return file, fileIdentifier, nil
+}
+
+// FileIdentifier returns the identifier of an open file as reported in
+// Line.FileIdentifier, "dev:inode" on Unix, without any inode generation
+// added by Config.UseInodeGeneration.
+func FileIdentifier(file *os.File) (string, error) {
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	return statIdentifier(fileInfo, file.Name())
+}
+
+// FileIdentifierForPath returns the FileIdentifier of the file at name,
+// following symlinks, without opening it.
+func FileIdentifierForPath(name string) (string, error) {
+	fileInfo, err := os.Stat(name)
+	if err != nil {
+		return "", err
+	}
+	return statIdentifier(fileInfo, name)
+}
 
+func statIdentifier(fileInfo os.FileInfo, name string) (string, error) {
 	sys, ok := fileInfo.Sys().(*syscall.Stat_t)
 	if !ok {
-		return nil, "", fmt.Errorf("failed to get file identifier for %s", name)
+		return "", fmt.Errorf("failed to get file identifier for %s", name)
+	}
+	return fmt.Sprintf("%d:%d", sys.Dev, sys.Ino), nil
+}
+
+// openNonBlocking is OpenFile with O_NONBLOCK, see Config.NonBlockingOpen.
+func openNonBlocking(name string) (file *os.File, fileIdentifier string, err error) {
+	file, err = os.OpenFile(name, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	fileIdentifier, err = FileIdentifier(file)
+	if err != nil {
+		file.Close()
+		return nil, "", err
 	}
-	return file, fmt.Sprintf("%d:%d", sys.Dev, sys.Ino), nil
+	return file, fileIdentifier, nil
 }
@@ -0,0 +1,153 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package tail
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestTail_WaitForReadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("file permissions are not enforced for root")
+	}
+
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+	noError(t, os.Chmod(testFile, 0))
+
+	tailer, err := TailFile(testFile, Config{Follow: true, MustExist: true, WaitForReadable: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+
+	select {
+	case line := <-tailer.Lines:
+		t.Fatalf("unexpected line before file became readable: %q", line.Text)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	noError(t, os.Chmod(testFile, 0644))
+
+	select {
+	case line := <-tailer.Lines:
+		eq(t, line.Text, "hello")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for line after chmod")
+	}
+}
+
+func TestTail_NonBlockingOpenFIFO(t *testing.T) {
+	fifo := filepath.Join(t.TempDir(), "fifo")
+	noError(t, syscall.Mkfifo(fifo, 0600))
+
+	tailer, err := TailFile(fifo, Config{Follow: true, Pipe: true, NonBlockingOpen: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	// Opened without a writer; tailing waits for one.
+	select {
+	case line, ok := <-tailer.Lines:
+		if !ok {
+			t.Fatalf("Lines closed: %v", tailer.Err())
+		}
+		t.Fatalf("unexpected line %q", line.Text)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	w, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	noError(t, err)
+	defer w.Close()
+	w.WriteString("hel")
+	time.Sleep(100 * time.Millisecond)
+	w.WriteString("lo\n")
+	eq(t, recvLine(t, tailer).Text, "hello")
+
+	// An idle writer does not keep Stop from returning.
+	stopped := make(chan error, 1)
+	go func() { stopped <- tailer.Stop() }()
+	select {
+	case err := <-stopped:
+		noError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop blocked on a read of the FIFO")
+	}
+}
+
+func TestTail_OnRawReadFIFO(t *testing.T) {
+	fifo := filepath.Join(t.TempDir(), "fifo")
+	noError(t, syscall.Mkfifo(fifo, 0600))
+
+	var mu sync.Mutex
+	var raw bytes.Buffer
+	onRawRead := func(chunk []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		raw.Write(chunk)
+	}
+	tailer, err := TailFile(fifo, Config{Follow: true, Pipe: true, NonBlockingOpen: true, OnRawRead: onRawRead, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	w, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	noError(t, err)
+	defer w.Close()
+	w.WriteString("hel")
+	time.Sleep(100 * time.Millisecond)
+	w.WriteString("lo\nworld\n")
+	eq(t, recvLine(t, tailer).Text, "hello")
+	eq(t, recvLine(t, tailer).Text, "world")
+	noError(t, tailer.Stop())
+
+	mu.Lock()
+	defer mu.Unlock()
+	eq(t, raw.String(), "hello\nworld\n")
+}
+
+func TestTail_OffsetOnReadError(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+
+	// The pipe holds the first line and the start of the second, and then
+	// fails to be read from once its deadline passes.
+	opener := func(name string) (*os.File, fs.FileInfo, error) {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		t.Cleanup(func() { w.Close() })
+		w.WriteString("hello\nwor")
+		r.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		return r, fi, nil
+	}
+	tailer, err := TailFile(testFile, Config{Follow: true, Opener: opener, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "hello")
+	eq(t, line.Offset, int64(6))
+
+	line = recvLine(t, tailer)
+	if !errors.Is(tailer.Wait(), os.ErrDeadlineExceeded) {
+		t.Fatalf("expected a read error, got %v", tailer.Err())
+	}
+	var readErr *ReadError
+	if !errors.As(line.Err, &readErr) {
+		t.Fatalf("expected a ReadError line, got %v", line.Err)
+	}
+	eq(t, readErr.Offset, int64(6))
+	eq(t, line.Text, "")
+	eq(t, line.Offset, int64(6))
+}
@@ -0,0 +1,2079 @@
+package tail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tenebris-tech/tail/watch"
+	"gopkg.in/tomb.v1"
+)
+
+// unreadableOpener returns an Opener whose first failures opens return a
+// file that fails to be read from, and the named file afterwards.
+func unreadableOpener(failures int) func(name string) (*os.File, fs.FileInfo, error) {
+	return func(name string) (*os.File, fs.FileInfo, error) {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if failures == 0 {
+			file, err := os.Open(name)
+			return file, fi, err
+		}
+		failures--
+		// A directory opens, and passes for the file with its FileInfo,
+		// but cannot be read.
+		file, err := os.Open(filepath.Dir(name))
+		return file, fi, err
+	}
+}
+
+// isReadFailure reports whether err is the read error of a file from
+// unreadableOpener.
+func isReadFailure(err error) bool {
+	var pathErr *fs.PathError
+	return errors.As(err, &pathErr) && pathErr.Op == "read"
+}
+
+func TestTail_ReadErrorRetriesExhausted(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, Opener: unreadableOpener(maxReadRetries + 1), Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	var errs int
+	for line := range tailer.Lines {
+		if !isReadFailure(line.Err) {
+			t.Fatalf("expected a read error, got %+v", line)
+		}
+		errs++
+	}
+	eq(t, errs, maxReadRetries+1)
+	if !isReadFailure(tailer.Wait()) {
+		t.Fatalf("expected a read error, got %v", tailer.Err())
+	}
+}
+
+func TestTail_OffsetOfUnterminatedLine(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\nwor")
+
+	tailer, err := TailFile(testFile, Config{Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	line := recvLine(t, tailer)
+	eq(t, line.Offset, int64(6))
+	eq(t, line.Partial, false)
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "wor")
+	eq(t, line.Offset, int64(6))
+	eq(t, line.Partial, true)
+}
+
+func TestTail_KeepDelimiter(t *testing.T) {
+	content := "one\r\ntwo\nthree\r\nfour"
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString(content)
+
+	tailer, err := TailFile(testFile, Config{KeepDelimiter: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	var got string
+	var offsets []int64
+	for line := range tailer.Lines {
+		got += line.Text
+		offsets = append(offsets, line.Offset)
+	}
+	noError(t, tailer.Wait())
+	eq(t, got, content)
+	eq(t, offsets, []int64{5, 9, 16, 16})
+}
+
+func TestTail_Quiesce(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, Logger: DiscardingLogger})
+	noError(t, err)
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "hello")
+	noError(t, tailer.Quiesce())
+
+	// No more lines are delivered once quiesced.
+	f.WriteString("world\n")
+	_, ok := <-tailer.Lines
+	eq(t, ok, false)
+
+	offset, err := tailer.Tell()
+	noError(t, err)
+	eq(t, offset, int64(6))
+	lag, err := tailer.Lag()
+	noError(t, err)
+	eq(t, lag, int64(6))
+
+	tailer.Cleanup()
+	offset, err = tailer.Tell()
+	noError(t, err)
+	eq(t, offset, int64(0))
+}
+
+func TestTail_LagAfterRotation(t *testing.T) {
+	testFile, f := testFile(t)
+	f.WriteString("hello\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	defer stopAndDrain(tailer)
+	eq(t, recvLine(t, tailer).Text, "hello")
+
+	f.Close()
+	noError(t, os.Rename(testFile, testFile+".1"))
+	noError(t, os.WriteFile(testFile, []byte("a\nb\n"), 0600))
+	eq(t, recvLine(t, tailer).Text, "a")
+
+	// "b" is held by the tailer until received.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		lag, err := tailer.Lag()
+		noError(t, err)
+		if lag == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a lag of 2 bytes in the new file, got %d", lag)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTail_AtEOF(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("a\nb\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	defer stopAndDrain(tailer)
+
+	waitAtEOF := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for tailer.AtEOF() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected AtEOF() to be %v", want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// The tailer is blocked sending "a".
+	eq(t, tailer.AtEOF(), false)
+	eq(t, recvLine(t, tailer).Text, "a")
+	eq(t, recvLine(t, tailer).Text, "b")
+	waitAtEOF(true)
+
+	// A partial line is pending.
+	f.WriteString("c")
+	waitAtEOF(false)
+	f.WriteString("\n")
+	eq(t, recvLine(t, tailer).Text, "c")
+	waitAtEOF(true)
+}
+
+func TestTail_SkipLines(t *testing.T) {
+	testFile, f := testFile(t)
+	f.WriteString("h1\nh2\nx\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, SkipLines: 2, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "x")
+	eq(t, line.Num, 3)
+	eq(t, line.Offset, int64(8))
+	f.WriteString("y\n")
+	eq(t, recvLine(t, tailer).Text, "y")
+
+	// The new file has a header too.
+	f.Close()
+	noError(t, os.Rename(testFile, testFile+".1"))
+	noError(t, os.WriteFile(testFile, []byte("h1\nh2\nz\n"), 0600))
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "z")
+	eq(t, line.Num, 3)
+}
+
+func TestTail_ReadBufferSize(t *testing.T) {
+	for _, tc := range []struct {
+		size int
+		want int // size of the buffer
+	}{
+		{16, 4096}, // never less than bufio's default
+		{1 << 20, 1 << 20},
+	} {
+		testFile, f := testFile(t)
+		defer f.Close()
+		var offsets []int64
+		var offset int64
+		for i := 0; i < 10000; i++ {
+			n, _ := fmt.Fprintf(f, "line %d\n", i)
+			offset += int64(n)
+			offsets = append(offsets, offset)
+		}
+
+		tailer, err := TailFile(testFile, Config{ReadBufferSize: tc.size, Logger: DiscardingLogger})
+		noError(t, err)
+		var got []int64
+		for line := range tailer.Lines {
+			got = append(got, line.Offset)
+		}
+		noError(t, tailer.Wait())
+		tailer.Cleanup()
+
+		eq(t, got, offsets)
+		eq(t, tailer.reader.Size(), tc.want)
+	}
+}
+
+func TestTail_EmitRecoveryMarkers(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\nworld\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, Opener: unreadableOpener(2), EmitRecoveryMarkers: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+
+	for i := 0; i < 2; i++ {
+		line := recvLine(t, tailer)
+		if !isReadFailure(line.Err) {
+			t.Fatalf("expected a read error, got %v", line.Err)
+		}
+		eq(t, line.Offset, int64(0))
+	}
+
+	line := recvLine(t, tailer)
+	eq(t, line.Recovered, true)
+	eq(t, line.ErrorCount, 2)
+	eq(t, line.Offset, int64(0))
+
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "hello")
+	eq(t, line.Recovered, false)
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "world")
+	eq(t, line.Recovered, false)
+}
+
+func TestTail_SeparateErrors(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, Opener: unreadableOpener(2), SeparateErrors: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "hello")
+	for i := 0; i < 2; i++ {
+		if err := <-tailer.Errors; !isReadFailure(err) {
+			t.Fatalf("expected a read error, got %v", err)
+		}
+	}
+	noError(t, tailer.Stop())
+	if _, ok := <-tailer.Errors; ok {
+		t.Fatal("expected Errors to be closed")
+	}
+}
+
+func TestTail_MaxBytes(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\ntwo\nthree\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, MaxBytes: 8, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	eq(t, recvLine(t, tailer).Text, "one")
+	eq(t, recvLine(t, tailer).Text, "two")
+	eq(t, recvLine(t, tailer).Err, ErrByteQuotaExceeded)
+	_, ok := <-tailer.Lines
+	eq(t, ok, false)
+	noError(t, tailer.Wait())
+	eq(t, tailer.StopReason(), ByteLimit)
+}
+
+func TestTail_MaxBytesStraddlingLine(t *testing.T) {
+	for _, emitPartial := range []bool{false, true} {
+		testFile, f := testFile(t)
+		f.WriteString("one\ntwo\nthree\n")
+		f.Close()
+
+		tailer, err := TailFile(testFile, Config{MaxBytes: 10, EmitPartialOnStop: emitPartial, Logger: DiscardingLogger})
+		noError(t, err)
+
+		var got []string
+		for line := range tailer.Lines {
+			if line.Err != nil {
+				eq(t, line.Err, ErrByteQuotaExceeded)
+				got = append(got, "<quota>")
+				continue
+			}
+			got = append(got, line.Text)
+			if line.Partial {
+				eq(t, line.Offset, int64(8))
+			}
+		}
+		noError(t, tailer.Wait())
+		tailer.Cleanup()
+		if emitPartial {
+			eq(t, got, []string{"one", "two", "th", "<quota>"})
+		} else {
+			eq(t, got, []string{"one", "two", "<quota>"})
+		}
+	}
+}
+
+func TestTail_MaxRuntime(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, MaxRuntime: 200 * time.Millisecond, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	eq(t, recvLine(t, tailer).Text, "hello")
+	select {
+	case _, ok := <-tailer.Lines:
+		eq(t, ok, false)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for MaxRuntime")
+	}
+	noError(t, tailer.Wait())
+	eq(t, tailer.StopReason(), TimeLimit)
+}
+
+func TestTail_MaxRuntimeEmitPartial(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\npar")
+
+	store := &fakePositionStore{}
+	tailer, err := TailFile(testFile, Config{Follow: true, MaxRuntime: 200 * time.Millisecond, EmitPartialOnStop: true, PositionStore: store, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	eq(t, recvLine(t, tailer).Text, "hello")
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "par")
+	eq(t, line.Partial, true)
+	_, ok := <-tailer.Lines
+	eq(t, ok, false)
+	noError(t, tailer.Wait())
+	eq(t, tailer.StopReason(), TimeLimit)
+	eq(t, store.offsets(), []int64{6})
+}
+
+func TestTail_OnRawRead(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\r\ntw")
+
+	var mu sync.Mutex
+	var raw bytes.Buffer
+	onRawRead := func(chunk []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		raw.Write(chunk)
+	}
+	tailer, err := TailFile(testFile, Config{Follow: true, OnRawRead: onRawRead, Logger: DiscardingLogger})
+	noError(t, err)
+
+	eq(t, recvLine(t, tailer).Text, "one\r")
+	// The partial line is re-read once complete but only reported once.
+	f.WriteString("o\nthree\n")
+	eq(t, recvLine(t, tailer).Text, "two")
+	eq(t, recvLine(t, tailer).Text, "three")
+	cleanTailer(tailer)
+
+	mu.Lock()
+	defer mu.Unlock()
+	eq(t, raw.String(), "one\r\ntwo\nthree\n")
+}
+
+func TestTail_ResetOnMatch(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\ntwo\nLOG RESET\nthree\nfour\n")
+
+	tailer, err := TailFile(testFile, Config{ResetOnMatch: regexp.MustCompile(`^LOG RESET$`), Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	type numbered struct {
+		Text  string
+		Num   int
+		Reset bool
+	}
+	var got []numbered
+	for line := range tailer.Lines {
+		got = append(got, numbered{line.Text, line.Num, line.Reset})
+	}
+	noError(t, tailer.Wait())
+	eq(t, got, []numbered{
+		{"one", 1, false},
+		{"two", 2, false},
+		{"LOG RESET", 3, false},
+		{"three", 1, true},
+		{"four", 2, false},
+	})
+}
+
+// failingWatcher simulates inotify failing to initialize.
+type failingWatcher struct {
+	err error
+}
+
+func (fw *failingWatcher) BlockUntilExists(*tomb.Tomb) error { return nil }
+
+func (fw *failingWatcher) ChangeEvents(*tomb.Tomb, int64) (*watch.FileChanges, error) {
+	return nil, fw.err
+}
+
+func TestTail_WatcherKindFallback(t *testing.T) {
+	saved := newInotifyWatcher
+	newInotifyWatcher = func(string) watch.FileWatcher {
+		return &failingWatcher{err: errors.New("inotify_init: too many open files")}
+	}
+	defer func() { newInotifyWatcher = saved }()
+
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, tailer.WatcherKind(), WatcherInotify)
+
+	eq(t, recvLine(t, tailer).Text, "hello")
+	f.WriteString("world\n")
+	eq(t, recvLine(t, tailer).Text, "world")
+	eq(t, tailer.WatcherKind(), WatcherPolling)
+}
+
+func TestTail_PollInterval(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+
+	slow, err := TailFile(testFile, Config{Follow: true, Poll: true, PollInterval: time.Hour, Logger: DiscardingLogger})
+	noError(t, err)
+	defer stopAndDrain(slow)
+	fast, err := TailFile(testFile, Config{Follow: true, Poll: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer fast.Stop()
+
+	eq(t, slow.watcher.(*watch.PollingFileWatcher).Interval, time.Hour)
+	eq(t, fast.watcher.(*watch.PollingFileWatcher).Interval, watch.POLL_DURATION)
+
+	// The fast tailer is unaffected by the slow one.
+	f.WriteString("hello\n")
+	eq(t, recvLine(t, fast).Text, "hello")
+	f.WriteString("world\n")
+	eq(t, recvLine(t, fast).Text, "world")
+}
+
+func TestTailFileContext(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tailer, err := TailFileContext(ctx, testFile, Config{Follow: true, Logger: DiscardingLogger})
+	noError(t, err)
+
+	eq(t, recvLine(t, tailer).Text, "hello")
+	cancel()
+	select {
+	case _, ok := <-tailer.Lines:
+		eq(t, ok, false)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Lines to close")
+	}
+	eq(t, tailer.Wait(), context.Canceled)
+	tailer.Cleanup()
+}
+
+func TestTail_TruncateLongLines(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("short\n" + strings.Repeat("x", 100) + "\nnext\n")
+
+	tailer, err := TailFile(testFile, Config{MaxLineSize: 10, TruncateLongLines: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "short")
+	eq(t, line.Truncated, false)
+	line = recvLine(t, tailer)
+	eq(t, line.Text, strings.Repeat("x", 10))
+	eq(t, line.Truncated, true)
+	eq(t, line.Offset, int64(107))
+	// The rest of the long line is not sent as a line of its own.
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "next")
+	eq(t, line.Truncated, false)
+	eq(t, line.Offset, int64(112))
+}
+
+func TestTail_Delimiter(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("a/b\x00c\nd\x00")
+
+	tailer, err := TailFile(testFile, Config{Delimiter: "\x00", Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "a/b")
+	eq(t, line.Offset, int64(4))
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "c\nd")
+	eq(t, line.Offset, int64(8))
+
+	// Resuming from an offset lands on the next record.
+	tailer, err = TailFile(testFile, Config{Delimiter: "\x00", Location: &SeekInfo{Offset: 4}, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	eq(t, recvLine(t, tailer).Text, "c\nd")
+
+	_, err = TailFile(testFile, Config{Delimiter: "\r\n"})
+	if err == nil {
+		t.Fatal("expected error for multi-byte delimiter")
+	}
+}
+
+func TestTail_LineBytes(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\ntwo\n")
+
+	tailer, err := TailFile(testFile, Config{OmitText: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	// Bytes stay valid after later lines have been read.
+	first := recvLine(t, tailer)
+	second := recvLine(t, tailer)
+	eq(t, string(first.Bytes), "one")
+	eq(t, string(second.Bytes), "two")
+	eq(t, first.Text, "")
+}
+
+func TestTail_Wait(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	eq(t, recvLine(t, tailer).Text, "hello")
+
+	errFatal := errors.New("fatal")
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = tailer.Wait()
+		}(i)
+	}
+	tailer.Kill(errFatal)
+	wg.Wait()
+	eq(t, errs, []error{errFatal, errFatal, errFatal})
+	_, ok := <-tailer.Lines
+	eq(t, ok, false)
+
+	// StopAtEOF is a requested stop, not an error.
+	tailer, err = TailFile(testFile, Config{Follow: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	eq(t, recvLine(t, tailer).Text, "hello")
+	noError(t, tailer.StopAtEOF())
+	noError(t, tailer.Wait())
+}
+
+func TestTail_ReadToEOF(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\ntwo\nthree\n")
+
+	tailer, err := TailFile(testFile, Config{Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	var texts []string
+	var offsets []int64
+	for line := range tailer.Lines {
+		texts = append(texts, line.Text)
+		offsets = append(offsets, line.Offset)
+	}
+	noError(t, tailer.Wait())
+	eq(t, texts, []string{"one", "two", "three"})
+	eq(t, offsets, []int64{4, 8, 14})
+
+	// Resuming from a checkpointed offset reads the rest.
+	tailer, err = TailFile(testFile, Config{Location: &SeekInfo{Offset: offsets[0]}, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	texts = nil
+	for line := range tailer.Lines {
+		texts = append(texts, line.Text)
+	}
+	eq(t, texts, []string{"two", "three"})
+}
+
+func TestTail_WatcherFallbackLogged(t *testing.T) {
+	errInotify := errors.New("inotify_init: function not implemented")
+	saved := newInotifyWatcher
+	newInotifyWatcher = func(string) watch.FileWatcher {
+		return &failingWatcher{err: errInotify}
+	}
+	defer func() { newInotifyWatcher = saved }()
+
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	var mu sync.Mutex
+	var buf bytes.Buffer
+	logger := log.New(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return buf.Write(p)
+	}), "", 0)
+	tailer, err := TailFile(testFile, Config{Follow: true, Logger: logger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+
+	eq(t, recvLine(t, tailer).Text, "hello")
+	f.WriteString("world\n")
+	eq(t, recvLine(t, tailer).Text, "world")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.Contains(buf.String(), "polling for "+testFile+": "+errInotify.Error()) {
+		t.Fatalf("fallback not logged: %q", buf.String())
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// countingWatcher counts the calls to a wrapped watcher.
+type countingWatcher struct {
+	watch.FileWatcher
+	mu    sync.Mutex
+	calls int
+}
+
+func (cw *countingWatcher) ChangeEvents(t *tomb.Tomb, pos int64) (*watch.FileChanges, error) {
+	cw.mu.Lock()
+	cw.calls++
+	cw.mu.Unlock()
+	return cw.FileWatcher.ChangeEvents(t, pos)
+}
+
+func TestTail_CustomWatcher(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	fw := watch.NewPollingFileWatcher(testFile)
+	fw.Interval = 10 * time.Millisecond
+	cw := &countingWatcher{FileWatcher: fw}
+	tailer, err := TailFile(testFile, Config{Follow: true, Watcher: cw, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, tailer.WatcherKind(), WatcherCustom)
+
+	eq(t, recvLine(t, tailer).Text, "hello")
+	f.WriteString("world\n")
+	eq(t, recvLine(t, tailer).Text, "world")
+
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if cw.calls == 0 {
+		t.Fatal("custom watcher was not used")
+	}
+}
+
+func TestTail_LineTime(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		testFile, f := testFile(t)
+		defer f.Close()
+
+		tailer, err := TailFile(testFile, Config{Follow: true, Poll: poll, Logger: DiscardingLogger})
+		noError(t, err)
+		defer cleanTailer(tailer)
+
+		before := time.Now()
+		f.WriteString("hello\n")
+		line := recvLine(t, tailer)
+		if line.Time.Before(before) || line.Time.After(time.Now()) {
+			t.Fatalf("poll=%v: line time %v not when the line was read", poll, line.Time)
+		}
+	}
+}
+
+func TestTail_OnReopen(t *testing.T) {
+	testFile, f := testFile(t)
+	f.WriteString("hello\n")
+
+	var mu sync.Mutex
+	var reopens [][2]string
+	onReopen := func(oldID, newID string) {
+		mu.Lock()
+		defer mu.Unlock()
+		reopens = append(reopens, [2]string{oldID, newID})
+	}
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, OnReopen: onReopen, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "hello")
+	oldID := line.FileIdentifier
+
+	f.Close()
+	noError(t, os.Rename(testFile, testFile+".1"))
+	f, err = os.Create(testFile)
+	noError(t, err)
+	defer f.Close()
+	f.WriteString("world\n")
+
+	// The callback has run by the time the first new line arrives.
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "world")
+	mu.Lock()
+	defer mu.Unlock()
+	eq(t, reopens, [][2]string{{oldID, line.FileIdentifier}})
+}
+
+func TestTail_LineSplitAcrossWrites(t *testing.T) {
+	for name, config := range map[string]Config{
+		"inotify":  {},
+		"poll":     {Poll: true, PollInterval: 10 * time.Millisecond},
+		"truncate": {MaxLineSize: 100, TruncateLongLines: true},
+		"json":     {JSONMode: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			testFile, f := testFile(t)
+			defer f.Close()
+
+			config.Follow = true
+			config.Logger = DiscardingLogger
+			tailer, err := TailFile(testFile, config)
+			noError(t, err)
+			defer cleanTailer(tailer)
+
+			// Each write is noticed and read on its own.
+			f.WriteString("hel")
+			time.Sleep(100 * time.Millisecond)
+			f.WriteString("lo\n")
+			line := recvLine(t, tailer)
+			eq(t, line.Text, "hello")
+			eq(t, line.Offset, int64(6))
+			f.WriteString("world\n")
+			eq(t, recvLine(t, tailer).Text, "world")
+		})
+	}
+}
+
+func TestTail_Name(t *testing.T) {
+	var mu sync.Mutex
+	var logged bytes.Buffer
+	logger := log.New(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return logged.Write(p)
+	}), "", 0)
+
+	testFile := filepath.Join(t.TempDir(), "named.log")
+	tailer, err := TailFile(testFile, Config{Follow: true, Name: "app", Logger: logger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	time.Sleep(100 * time.Millisecond) // for the wait to be logged
+	noError(t, os.WriteFile(testFile, []byte("hello\n"), 0600))
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "hello")
+	eq(t, line.Tag, "app")
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.HasPrefix(logged.String(), "[app] Waiting for "+testFile) {
+		t.Fatalf("expected a log prefixed with the name, got %q", logged.String())
+	}
+}
+
+func TestTail_Opener(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	var opened []string
+	opener := func(name string) (*os.File, fs.FileInfo, error) {
+		opened = append(opened, name)
+		file, err := os.Open(name)
+		return file, nil, err
+	}
+	tailer, err := TailFile(testFile, Config{Opener: opener, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	eq(t, recvLine(t, tailer).Text, "hello")
+	noError(t, tailer.Wait())
+	eq(t, opened, []string{testFile})
+
+	errOpen := errors.New("injected open error")
+	opener = func(name string) (*os.File, fs.FileInfo, error) {
+		return nil, nil, errOpen
+	}
+	tailer, err = TailFile(testFile, Config{Opener: opener, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	if _, ok := <-tailer.Lines; ok {
+		t.Fatal("expected Lines to be closed")
+	}
+	if err := tailer.Wait(); err == nil || !strings.Contains(err.Error(), errOpen.Error()) {
+		t.Fatalf("expected the open error, got %v", err)
+	}
+}
+
+func TestTail_WatchDir(t *testing.T) {
+	testFile, f := testFile(t)
+	f.WriteString("hello\n")
+	f.Close()
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, WatchDir: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, recvLine(t, tailer).Text, "hello")
+
+	noError(t, os.Rename(testFile, testFile+".1"))
+	time.Sleep(50 * time.Millisecond)
+	noError(t, os.WriteFile(testFile, []byte("world\n"), 0600))
+	eq(t, recvLine(t, tailer).Text, "world")
+}
+
+func TestTail_OnFileAppear(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "later.log")
+	appeared := make(chan string, 1)
+	tailer, err := TailFile(testFile, Config{Follow: true, OnFileAppear: func(name string) { appeared <- name }, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+
+	select {
+	case name := <-appeared:
+		t.Fatalf("OnFileAppear called with %s before the file exists", name)
+	case <-time.After(100 * time.Millisecond):
+	}
+	noError(t, os.WriteFile(testFile, nil, 0600))
+	select {
+	case name := <-appeared:
+		eq(t, name, testFile)
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnFileAppear not called")
+	}
+}
+
+func TestTail_OnTruncate(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello world\n")
+
+	var mu sync.Mutex
+	var truncations [][2]int64
+	onTruncate := func(oldSize, newSize int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		truncations = append(truncations, [2]int64{oldSize, newSize})
+	}
+	tailer, err := TailFile(testFile, Config{Follow: true, OnTruncate: onTruncate, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, recvLine(t, tailer).Text, "hello world")
+
+	_, err = f.WriteAt([]byte("hi\n"), 0)
+	noError(t, err)
+	noError(t, f.Truncate(3))
+
+	// The callback has run by the time the first line after it arrives.
+	eq(t, recvLine(t, tailer).Text, "hi")
+	mu.Lock()
+	defer mu.Unlock()
+	eq(t, truncations, [][2]int64{{12, 3}})
+}
+
+func TestTail_OverflowPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy OverflowPolicy
+		want   []string
+	}{
+		{DropOldest, []string{"4", "5"}},
+		{DropNewest, []string{"1", "2"}},
+	} {
+		testFile, f := testFile(t)
+		defer f.Close()
+		f.WriteString("1\n2\n3\n4\n5\n")
+
+		tailer, err := TailFile(testFile, Config{MaxBufferedLines: 2, OverflowPolicy: tc.policy, Logger: DiscardingLogger})
+		noError(t, err)
+		defer tailer.Cleanup()
+
+		// Nothing is read until the tailer has finished.
+		noError(t, tailer.Wait())
+		var got []string
+		for line := range tailer.Lines {
+			got = append(got, line.Text)
+		}
+		eq(t, got, tc.want)
+		eq(t, tailer.Dropped(), uint64(3))
+	}
+}
+
+func TestTail_TrimCR(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\r\ntw\ro\n\r\nthree\r")
+
+	tailer, err := TailFile(testFile, Config{TrimCR: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	var texts []string
+	var offsets []int64
+	for line := range tailer.Lines {
+		texts = append(texts, line.Text)
+		offsets = append(offsets, line.Offset)
+	}
+	// A carriage return not followed by a newline is kept.
+	eq(t, texts, []string{"one", "tw\ro", "", "three\r"})
+	eq(t, offsets, []int64{5, 10, 12, 12})
+}
+
+func TestTail_RateLimit(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("1\n2\n3\n4\n5\n")
+
+	start := time.Now()
+	tailer, err := TailFile(testFile, Config{RateLimit: 20, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	n := 0
+	for range tailer.Lines {
+		n++
+	}
+	eq(t, n, 5)
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("5 lines at 20/s took only %v", elapsed)
+	}
+
+	// Stop is not held up by a long wait for the next line.
+	tailer, err = TailFile(testFile, Config{Follow: true, RateLimit: 0.1, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	eq(t, recvLine(t, tailer).Text, "1")
+	time.Sleep(10 * time.Millisecond)
+	start = time.Now()
+	noError(t, tailer.Stop())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Stop took %v", elapsed)
+	}
+}
+
+func TestTailReader(t *testing.T) {
+	tailer, err := TailReader(strings.NewReader("one\x00two\x00three"), Config{Delimiter: "\x00", Location: &SeekInfo{Offset: 4}, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	var texts []string
+	var offsets []int64
+	for line := range tailer.Lines {
+		texts = append(texts, line.Text)
+		offsets = append(offsets, line.Offset)
+	}
+	noError(t, tailer.Wait())
+	eq(t, texts, []string{"two", "three"})
+	eq(t, offsets, []int64{8, 8})
+
+	// A plain reader cannot honor Location.
+	_, err = TailReader(io.MultiReader(strings.NewReader("")), Config{Location: &SeekInfo{}})
+	if err == nil {
+		t.Fatal("expected error for Location without an io.Seeker")
+	}
+	_, err = TailReader(io.MultiReader(strings.NewReader("")), Config{SeekEnd: true})
+	if err == nil {
+		t.Fatal("expected error for SeekEnd without an io.Seeker")
+	}
+
+	// Nor can it be sought later on.
+	plain := &Tail{source: io.MultiReader(strings.NewReader(""))}
+	_, err = plain.resolveSeek(SeekInfo{Whence: io.SeekEnd})
+	eq(t, err, errNotSeeker)
+	eq(t, plain.seekRunning(SeekInfo{}), errNotSeeker)
+}
+
+func TestTailStdin(t *testing.T) {
+	r, w, err := os.Pipe()
+	noError(t, err)
+	defer r.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	tailer, err := TailStdin(Config{Location: &SeekInfo{Offset: 4}, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	w.WriteString("one\ntwo\n")
+	eq(t, recvLine(t, tailer).Text, "one")
+	eq(t, recvLine(t, tailer).Text, "two")
+	w.WriteString("three\n")
+	w.Close()
+	eq(t, recvLine(t, tailer).Text, "three")
+	if _, ok := <-tailer.Lines; ok {
+		t.Fatal("expected Lines to be closed at EOF")
+	}
+	noError(t, tailer.Wait())
+}
+
+func TestTail_TruncateThenAppend(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		testFile, f := testFile(t)
+		defer f.Close()
+		f.WriteString("hello\n")
+
+		tailer, err := TailFile(testFile, Config{Follow: true, Poll: poll, Logger: DiscardingLogger})
+		noError(t, err)
+		defer cleanTailer(tailer)
+		eq(t, recvLine(t, tailer).Text, "hello")
+
+		// The new line is longer than the old content, so by the time the
+		// tailer looks the file has not shrunk.
+		noError(t, f.Truncate(0))
+		f.Seek(0, io.SeekStart)
+		f.WriteString("a longer line after truncation\n")
+		eq(t, recvLine(t, tailer).Text, "a longer line after truncation")
+	}
+}
+
+func TestTail_WaitForFileTimeout(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "missing.log")
+	tailer, err := TailFile(testFile, Config{Follow: true, WaitForFileTimeout: 100 * time.Millisecond, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	select {
+	case _, ok := <-tailer.Lines:
+		eq(t, ok, false)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for WaitForFileTimeout")
+	}
+	if err := tailer.Wait(); !errors.Is(err, ErrFileTimeout) {
+		t.Fatalf("expected ErrFileTimeout, got %v", err)
+	}
+}
+
+func TestTail_MaxReopenAttempts(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		t.Run(fmt.Sprintf("poll=%v", poll), func(t *testing.T) {
+			testFile := filepath.Join(t.TempDir(), "reopen.log")
+			noError(t, os.WriteFile(testFile, []byte("hello\n"), 0600))
+
+			config := Config{Follow: true, ReOpen: true, Poll: poll, MaxReopenAttempts: 3, ReopenBackoff: 20 * time.Millisecond, Logger: DiscardingLogger}
+			tailer, err := TailFile(testFile, config)
+			noError(t, err)
+			defer tailer.Cleanup()
+			eq(t, recvLine(t, tailer).Text, "hello")
+			noError(t, os.Remove(testFile))
+
+			select {
+			case _, ok := <-tailer.Lines:
+				eq(t, ok, false)
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for reopen attempts to run out")
+			}
+			if err := tailer.Wait(); !errors.Is(err, ErrReopenFailed) {
+				t.Fatalf("expected ErrReopenFailed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTail_DetectInPlaceReset(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		t.Run(fmt.Sprintf("poll=%v", poll), func(t *testing.T) {
+			testFile := filepath.Join(t.TempDir(), "reset.log")
+			noError(t, os.WriteFile(testFile, []byte("hello\nworld\n"), 0600))
+
+			config := Config{Follow: true, Poll: poll, PollInterval: 20 * time.Millisecond, DetectInPlaceReset: true, Logger: DiscardingLogger}
+			tailer, err := TailFile(testFile, config)
+			noError(t, err)
+			defer cleanTailer(tailer)
+			eq(t, recvLine(t, tailer).Text, "hello")
+			eq(t, recvLine(t, tailer).Text, "world")
+			time.Sleep(100 * time.Millisecond)
+
+			// Same size, same inode and still a delimiter before the
+			// read position.
+			f, err := os.OpenFile(testFile, os.O_WRONLY, 0)
+			noError(t, err)
+			_, err = f.WriteAt([]byte("HELLO\nWORLD\n"), 0)
+			noError(t, err)
+			f.Close()
+
+			line := recvLine(t, tailer)
+			eq(t, line.Text, "HELLO")
+			eq(t, line.Reset, true)
+			eq(t, recvLine(t, tailer).Text, "WORLD")
+		})
+	}
+}
+
+func TestTail_LocationAfterTruncation(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"intact", "hello\nworld\n", "world"},
+		{"smaller", "hi\n", "hi"},
+		{"regrown", "a much longer line\n", "a much longer line"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testFile, f := testFile(t)
+			defer f.Close()
+			f.WriteString("hello\nworld\n")
+			id, err := FileIdentifier(f)
+			noError(t, err)
+			saved := SeekInfo{Offset: 6, Whence: io.SeekStart, FileIdentifier: id}
+
+			// Same file, truncated and written again while not tailed.
+			noError(t, f.Truncate(0))
+			_, err = f.WriteAt([]byte(tc.content), 0)
+			noError(t, err)
+
+			tailer, err := TailFile(testFile, Config{Location: &saved, Logger: DiscardingLogger})
+			noError(t, err)
+			defer tailer.Cleanup()
+			eq(t, recvLine(t, tailer).Text, tc.want)
+		})
+	}
+}
+
+func TestTail_AlignToLine(t *testing.T) {
+	long := strings.Repeat("x", 5000)
+	content := "hello\nworld\n" + long + "\nagain\n"
+	for _, tc := range []struct {
+		offset int64
+		want   string
+		end    int64
+	}{
+		{0, "hello", 6},
+		{3, "hello", 6},
+		{6, "world", 12},
+		{11, "world", 12},
+		{12 + 4500, long, 5013},
+		{5013, "again", 5019},
+	} {
+		testFile, f := testFile(t)
+		f.WriteString(content)
+		f.Close()
+
+		tailer, err := TailFile(testFile, Config{Location: &SeekInfo{Offset: tc.offset}, AlignToLine: true, Logger: DiscardingLogger})
+		noError(t, err)
+		line := recvLine(t, tailer)
+		eq(t, line.Text, tc.want)
+		eq(t, line.Offset, tc.end)
+		stopAndDrain(tailer)
+		tailer.Cleanup()
+	}
+}
+
+func TestTail_AlignToLineUTF16(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	// "ab\ncd\n" in UTF-16LE; offset 9 is inside "cd".
+	f.Write([]byte("a\x00b\x00\n\x00c\x00d\x00\n\x00"))
+
+	tailer, err := TailFile(testFile, Config{Location: &SeekInfo{Offset: 9}, AlignToLine: true, Encoding: UTF16LE, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "cd")
+	eq(t, line.Offset, int64(12))
+}
+
+func TestTail_LocationInclusive(t *testing.T) {
+	for _, tc := range []struct {
+		loc  SeekInfo
+		want string
+	}{
+		{SeekInfo{Offset: 4}, "two"},
+		{SeekInfo{Offset: 4, Inclusive: true}, "one"},
+		{SeekInfo{Offset: 8, Inclusive: true}, "two"},
+		{SeekInfo{Offset: 0, Inclusive: true}, "one"},
+		{SeekInfo{Offset: 0, Whence: io.SeekEnd, Inclusive: true}, "three"},
+		// Not at the end of a line.
+		{SeekInfo{Offset: 5, Inclusive: true}, "wo"},
+	} {
+		testFile, f := testFile(t)
+		f.WriteString("one\ntwo\nthree\n")
+		f.Close()
+
+		loc := tc.loc
+		tailer, err := TailFile(testFile, Config{Location: &loc, Logger: DiscardingLogger})
+		noError(t, err)
+		eq(t, recvLine(t, tailer).Text, tc.want)
+		stopAndDrain(tailer)
+		tailer.Cleanup()
+	}
+}
+
+func TestTail_SeekInclusive(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "seek.log")
+	noError(t, os.WriteFile(testFile, []byte("1\n2\n3\n"), 0600))
+
+	tailer, err := TailFile(testFile, Config{Follow: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	defer stopAndDrain(tailer)
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "1")
+
+	// Deliver the line checkpointed last again.
+	noError(t, tailer.Seek(SeekInfo{Offset: line.Offset, Inclusive: true}))
+	eq(t, recvLine(t, tailer).Text, "1")
+	eq(t, recvLine(t, tailer).Text, "2")
+}
+
+func TestTail_Seek(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "seek.log")
+	noError(t, os.WriteFile(testFile, []byte("1\n2\n3\n4\n5\n"), 0600))
+
+	tailer, err := TailFile(testFile, Config{Follow: true, MaxBufferedLines: 10, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, recvLine(t, tailer).Text, "1")
+
+	// Lines 2 to 5 are buffered or being sent; none of them may show up.
+	noError(t, tailer.Seek(SeekInfo{Offset: 6, Whence: io.SeekStart}))
+	eq(t, recvLine(t, tailer).Text, "4")
+	eq(t, recvLine(t, tailer).Text, "5")
+
+	// Idle at EOF.
+	noError(t, tailer.Seek(SeekInfo{Offset: 0, Whence: io.SeekStart}))
+	eq(t, recvLine(t, tailer).Text, "1")
+
+	noError(t, tailer.Seek(SeekInfo{Offset: -2, Whence: io.SeekEnd}))
+	eq(t, recvLine(t, tailer).Text, "5")
+}
+
+func TestTail_SeekUnbuffered(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "seek.log")
+	noError(t, os.WriteFile(testFile, []byte("1\n2\n3\n"), 0600))
+
+	tailer, err := TailFile(testFile, Config{Follow: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	defer stopAndDrain(tailer)
+	eq(t, recvLine(t, tailer).Text, "1")
+
+	// The tailer is blocked sending line 2.
+	noError(t, tailer.Seek(SeekInfo{Offset: 0, Whence: io.SeekStart}))
+	eq(t, recvLine(t, tailer).Text, "1")
+	eq(t, recvLine(t, tailer).Text, "2")
+}
+
+func TestTail_SeekStopped(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "seek.log")
+	noError(t, os.WriteFile(testFile, []byte("1\n"), 0600))
+
+	tailer, err := TailFile(testFile, Config{Logger: DiscardingLogger})
+	noError(t, err)
+	for range tailer.Lines {
+	}
+	if err := tailer.Seek(SeekInfo{}); err != ErrNotRunning {
+		t.Fatalf("expected ErrNotRunning, got %v", err)
+	}
+}
+
+func TestTail_Reload(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("1\n")
+
+	config := Config{Follow: true, Poll: true, PollInterval: time.Hour, Logger: DiscardingLogger}
+	tailer, err := TailFile(testFile, config)
+	noError(t, err)
+	defer tailer.Cleanup()
+	defer stopAndDrain(tailer)
+	eq(t, recvLine(t, tailer).Text, "1")
+
+	// Idle at EOF, polling once an hour.
+	config.PollInterval = 10 * time.Millisecond
+	config.LineFilter = func(l *Line) bool { return l.Text != "skip" }
+	noError(t, tailer.Reload(config))
+	f.WriteString("skip\n2\n")
+	eq(t, recvLine(t, tailer).Text, "2")
+
+	config.Poll = false
+	if err := tailer.Reload(config); err == nil {
+		t.Fatal("expected an error switching off Poll")
+	}
+	config.Poll, config.MaxLineSize = true, -1
+	if err := tailer.Reload(config); err == nil {
+		t.Fatal("expected an error for an invalid config")
+	}
+}
+
+func TestTail_ReloadStopped(t *testing.T) {
+	tailer, err := TailReader(strings.NewReader("1\n"), Config{Logger: DiscardingLogger})
+	noError(t, err)
+	for range tailer.Lines {
+	}
+	if err := tailer.Reload(tailer.Config); err != ErrNotRunning {
+		t.Fatalf("expected ErrNotRunning, got %v", err)
+	}
+}
+
+func TestTail_EmitPartialOnStop(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\nwor")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, EmitPartialOnStop: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	eq(t, recvLine(t, tailer).Text, "hello")
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- tailer.Stop() }()
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "wor")
+	eq(t, line.Partial, true)
+	eq(t, line.Offset, int64(6))
+	noError(t, <-stopped)
+	if _, ok := <-tailer.Lines; ok {
+		t.Fatal("expected Lines to be closed")
+	}
+}
+
+func TestTail_EmitPartialOnStopPaused(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\nwor")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, EmitPartialOnStop: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	eq(t, recvLine(t, tailer).Text, "hello")
+
+	// Stopped while paused rather than waiting for changes.
+	tailer.Pause()
+	stopped := make(chan error, 1)
+	go func() { stopped <- tailer.Stop() }()
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "wor")
+	eq(t, line.Partial, true)
+	noError(t, <-stopped)
+}
+
+func TestMustTailFile(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	tailer := MustTailFile(testFile, Config{Logger: DiscardingLogger})
+	defer tailer.Cleanup()
+	eq(t, recvLine(t, tailer).Text, "hello")
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a missing file")
+		}
+	}()
+	MustTailFile(testFile+".missing", Config{MustExist: true, Logger: DiscardingLogger})
+}
+
+func TestTail_Position(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "position.log")
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+
+	if _, err := tailer.Position(); err == nil {
+		t.Fatal("expected an error before the file is opened")
+	}
+
+	noError(t, os.WriteFile(testFile, []byte("one\ntwo\n"), 0600))
+	recvLine(t, tailer)
+	line := recvLine(t, tailer)
+
+	// Idle at EOF, the position stays past the last line.
+	time.Sleep(50 * time.Millisecond)
+	pos, err := tailer.Position()
+	noError(t, err)
+	eq(t, pos, SeekInfo{Offset: 8, Whence: io.SeekStart, FileIdentifier: line.FileIdentifier})
+
+	// After a rotation, it moves to the start of the new file.
+	noError(t, os.Rename(testFile, testFile+".1"))
+	noError(t, os.WriteFile(testFile, []byte("three\n"), 0600))
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "three")
+	pos, err = tailer.Position()
+	noError(t, err)
+	eq(t, pos.FileIdentifier, line.FileIdentifier)
+}
+
+func TestTail_FollowSymlinkTarget(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		dir := t.TempDir()
+		link := filepath.Join(dir, "0.log")
+		first := filepath.Join(dir, "first.log")
+		second := filepath.Join(dir, "second.log")
+		noError(t, os.WriteFile(first, []byte("first\n"), 0600))
+		noError(t, os.WriteFile(second, nil, 0600))
+		noError(t, os.Symlink(first, link))
+
+		tailer, err := TailFile(link, Config{Follow: true, ReOpen: true, Poll: poll, FollowSymlinkTarget: true, Logger: DiscardingLogger})
+		noError(t, err)
+		eq(t, recvLine(t, tailer).Text, "first")
+
+		// Repoint the symlink atomically; neither file is touched.
+		noError(t, os.WriteFile(second, []byte("second\n"), 0600))
+		noError(t, os.Symlink(second, link+".tmp"))
+		noError(t, os.Rename(link+".tmp", link))
+		eq(t, recvLine(t, tailer).Text, "second")
+		cleanTailer(tailer)
+	}
+}
+
+func TestTail_NilLogger(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	if tailer.Logger != DiscardLogger {
+		t.Fatal("expected a nil Logger to be replaced by DiscardLogger")
+	}
+	eq(t, recvLine(t, tailer).Text, "hello")
+
+	// Truncation and rotation both log.
+	noError(t, f.Truncate(0))
+	f.Seek(0, io.SeekStart)
+	f.WriteString("truncated\n")
+	eq(t, recvLine(t, tailer).Text, "truncated")
+
+	noError(t, os.Rename(testFile, testFile+".1"))
+	noError(t, os.WriteFile(testFile, []byte("rotated\n"), 0600))
+	eq(t, recvLine(t, tailer).Text, "rotated")
+}
+
+func TestTail_EventCoalesceWindow(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+
+	const window = 200 * time.Millisecond
+	tailer, err := TailFile(testFile, Config{Follow: true, EventCoalesceWindow: window, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+
+	// Let the tailer reach EOF and wait for changes.
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		f.WriteString("line\n")
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		eq(t, recvLine(t, tailer).Text, "line")
+		if i == 0 && time.Since(start) < window {
+			t.Fatalf("first line arrived after %v, before the window closed", time.Since(start))
+		}
+	}
+}
+
+func TestFileIdentifier(t *testing.T) {
+	testFile, f := testFile(t)
+	f.WriteString("hello\n")
+	oldID, err := FileIdentifier(f)
+	noError(t, err)
+	f.Close()
+
+	tailer, err := TailFile(testFile, Config{Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	eq(t, recvLine(t, tailer).FileIdentifier, oldID)
+
+	noError(t, os.Rename(testFile, testFile+".1"))
+	noError(t, os.WriteFile(testFile, []byte("world\n"), 0600))
+	f, _, err = OpenFile(testFile)
+	noError(t, err)
+	defer f.Close()
+	newID, err := FileIdentifier(f)
+	noError(t, err)
+	if newID == oldID {
+		t.Fatalf("replacement file has the same identifier %q", oldID)
+	}
+}
+
+func TestFileIdentifierForPath(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	id, err := FileIdentifier(f)
+	noError(t, err)
+	pathID, err := FileIdentifierForPath(testFile)
+	noError(t, err)
+	eq(t, pathID, id)
+
+	// The open file keeps its identifier once moved.
+	noError(t, os.Rename(testFile, testFile+".1"))
+	pathID, err = FileIdentifierForPath(testFile + ".1")
+	noError(t, err)
+	eq(t, pathID, id)
+
+	if _, err := FileIdentifierForPath(testFile); !os.IsNotExist(err) {
+		t.Fatalf("expected a not-exist error, got %v", err)
+	}
+	noError(t, os.WriteFile(testFile, nil, 0600))
+	pathID, err = FileIdentifierForPath(testFile)
+	noError(t, err)
+	if pathID == id {
+		t.Fatalf("replacement file has the same identifier %q", id)
+	}
+}
+
+func TestTail_NotRegularFile(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, recvLine(t, tailer).Text, "hello")
+
+	noError(t, os.Rename(testFile, testFile+".1"))
+	noError(t, os.Mkdir(testFile, 0755))
+	line := recvLine(t, tailer)
+	if !errors.Is(line.Err, ErrNotRegularFile) {
+		t.Fatalf("expected ErrNotRegularFile, got %v", line.Err)
+	}
+
+	noError(t, os.Remove(testFile))
+	noError(t, os.WriteFile(testFile, []byte("regular again\n"), 0600))
+	eq(t, recvLine(t, tailer).Text, "regular again")
+}
+
+func TestTail_NotRegularFileWithoutReOpen(t *testing.T) {
+	tailer, err := TailFile(t.TempDir(), Config{Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	for range tailer.Lines {
+	}
+	if err := tailer.Wait(); !errors.Is(err, ErrNotRegularFile) {
+		t.Fatalf("expected ErrNotRegularFile, got %v", err)
+	}
+}
+
+func TestTail_PauseResume(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, MaxBufferedLines: 10, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, recvLine(t, tailer).Text, "one")
+
+	tailer.Pause()
+	f.WriteString("two\n")
+	f.WriteString("three\n")
+	select {
+	case line := <-tailer.Lines:
+		t.Fatalf("unexpected line while paused: %q", line.Text)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	tailer.Resume()
+	eq(t, recvLine(t, tailer).Text, "two")
+	eq(t, recvLine(t, tailer).Text, "three")
+}
+
+func TestTail_PauseBlockedRead(t *testing.T) {
+	r, w := io.Pipe()
+	tailer, err := TailReader(r, Config{Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	defer w.Close()
+	go w.Write([]byte("one\n"))
+	eq(t, recvLine(t, tailer).Text, "one")
+
+	// The tailer is now blocked reading the idle pipe.
+	done := make(chan struct{})
+	go func() {
+		tailer.Pause()
+		tailer.StopReason()
+		tailer.WatcherKind()
+		tailer.Resume()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Pause blocked on the read")
+	}
+	go w.Write([]byte("two\n"))
+	eq(t, recvLine(t, tailer).Text, "two")
+}
+
+func TestTail_IdleTimeout(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\n")
+
+	const timeout = 300 * time.Millisecond
+	tailer, err := TailFile(testFile, Config{Follow: true, IdleTimeout: timeout, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	eq(t, recvLine(t, tailer).Text, "one")
+
+	// Writes within the timeout keep the tailer alive.
+	time.Sleep(timeout / 2)
+	f.WriteString("two\n")
+	eq(t, recvLine(t, tailer).Text, "two")
+	time.Sleep(timeout / 2)
+	f.WriteString("three\n")
+	eq(t, recvLine(t, tailer).Text, "three")
+
+	select {
+	case line, ok := <-tailer.Lines:
+		if ok {
+			t.Fatalf("unexpected line %q", line.Text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for IdleTimeout")
+	}
+	if err := tailer.Wait(); !errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("expected ErrIdleTimeout, got %v", err)
+	}
+}
+
+func TestTail_LineFilter(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("info one\ndebug two\ninfo three\n")
+
+	filter := func(l *Line) bool { return !strings.HasPrefix(l.Text, "debug") }
+	tailer, err := TailFile(testFile, Config{LineFilter: filter, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	var got []string
+	var nums []int
+	for line := range tailer.Lines {
+		got = append(got, line.Text)
+		nums = append(nums, line.Num)
+	}
+	eq(t, got, []string{"info one", "info three"})
+	eq(t, nums, []int{1, 3})
+	pos, err := tailer.Position()
+	noError(t, err)
+	eq(t, pos.Offset, int64(30))
+}
+
+func TestTail_MatchRegexp(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("GET /a 200\nGET /b 500\nPOST /c 503\nGET /d 200")
+
+	tailer, err := TailFile(testFile, Config{MatchRegexp: regexp.MustCompile(` 5\d\d$`), Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+
+	var got []string
+	var nums []int
+	for line := range tailer.Lines {
+		got = append(got, line.Text)
+		nums = append(nums, line.Num)
+	}
+	eq(t, got, []string{"GET /b 500", "POST /c 503"})
+	eq(t, nums, []int{2, 3})
+	pos, err := tailer.Position()
+	noError(t, err)
+	eq(t, pos.Offset, int64(34))
+}
+
+func TestTail_StopWhileBlocked(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		partial bool
+	}{
+		// The tailer is blocked sending "b".
+		{"line", "a\nb\nc\n", false},
+		// The tailer is blocked sending the final partial line.
+		{"partial", "a\npartial", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testFile, f := testFile(t)
+			f.WriteString(tc.content)
+			f.Close()
+
+			before := runtime.NumGoroutine()
+			tailer, err := TailFile(testFile, Config{Follow: true, Poll: true, EmitPartialOnStop: tc.partial, Logger: DiscardingLogger})
+			noError(t, err)
+			defer tailer.Cleanup()
+
+			// The consumer gives up after one line.
+			eq(t, recvLine(t, tailer).Text, "a")
+			done := make(chan error, 1)
+			go func() { done <- tailer.Stop() }()
+			select {
+			case err := <-done:
+				noError(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("Stop blocked on a consumer that stopped reading")
+			}
+			pos, err := tailer.Position()
+			noError(t, err)
+			eq(t, pos.Offset, int64(2))
+
+			deadline := time.Now().Add(5 * time.Second)
+			for runtime.NumGoroutine() > before {
+				if time.Now().After(deadline) {
+					t.Fatalf("%d goroutines left running, %d before", runtime.NumGoroutine(), before)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestTail_KeepChannelOpen(t *testing.T) {
+	first, err := TailReader(strings.NewReader("one\n"), Config{KeepChannelOpen: true, Logger: DiscardingLogger})
+	noError(t, err)
+	eq(t, recvLine(t, first).Text, "one")
+	noError(t, first.Wait())
+	select {
+	case line, ok := <-first.Lines:
+		t.Fatalf("unexpected receive after stopping: %v, %v", line, ok)
+	default:
+	}
+
+	// The next tailer sends on the same channel and closes it.
+	second, err := TailReader(strings.NewReader("two\n"), Config{Channel: first.Lines, Logger: DiscardingLogger})
+	noError(t, err)
+	eq(t, second.Lines, first.Lines)
+	eq(t, recvLine(t, second).Text, "two")
+	_, ok := <-first.Lines
+	eq(t, ok, false)
+}
+
+func TestTail_ConcurrentStop(t *testing.T) {
+	for _, quiesce := range []bool{false, true} {
+		testFile, f := testFile(t)
+		f.WriteString("hello\n")
+		f.Close()
+
+		tailer, err := TailFile(testFile, Config{Follow: true, Logger: DiscardingLogger})
+		noError(t, err)
+		eq(t, recvLine(t, tailer).Text, "hello")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				switch {
+				case i%2 == 1:
+					tailer.Cleanup()
+				case quiesce:
+					noError(t, tailer.Quiesce())
+				default:
+					noError(t, tailer.Stop())
+				}
+			}(i)
+		}
+		wg.Wait()
+		noError(t, tailer.Stop())
+		tailer.Cleanup()
+		if _, ok := <-tailer.Lines; ok {
+			t.Fatal("expected Lines to be closed")
+		}
+	}
+}
+
+func TestTail_LineFilename(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "app.log")
+	first := filepath.Join(dir, "first.log")
+	second := filepath.Join(dir, "second.log")
+	noError(t, os.WriteFile(first, []byte("first\n"), 0600))
+	noError(t, os.Symlink(first, link))
+
+	tailer, err := TailFile(link, Config{Follow: true, ReOpen: true, FollowSymlinkTarget: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	want, err := filepath.EvalSymlinks(first)
+	noError(t, err)
+	eq(t, recvLine(t, tailer).Filename, want)
+
+	noError(t, os.WriteFile(second, []byte("second\n"), 0600))
+	noError(t, os.Symlink(second, link+".tmp"))
+	noError(t, os.Rename(link+".tmp", link))
+	want, err = filepath.EvalSymlinks(second)
+	noError(t, err)
+	eq(t, recvLine(t, tailer).Filename, want)
+}
+
+func TestTail_LocationFromEnd(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\ntwo\nthree\n")
+
+	for _, tc := range []struct {
+		offset int64
+		want   []string
+	}{
+		{-6, []string{"three"}},
+		{-10, []string{"two", "three"}},
+		{-1000, []string{"one", "two", "three"}},
+		{0, nil},
+	} {
+		tailer, err := TailFile(testFile, Config{Location: &SeekInfo{Offset: tc.offset, Whence: io.SeekEnd}, Logger: DiscardingLogger})
+		noError(t, err)
+		var got []string
+		for line := range tailer.Lines {
+			got = append(got, line.Text)
+		}
+		noError(t, tailer.Wait())
+		tailer.Cleanup()
+		eq(t, got, tc.want)
+	}
+}
+
+func TestTail_UnsupportedWhence(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+
+	for _, whence := range []int{io.SeekCurrent, 3} {
+		_, err := TailFile(testFile, Config{Location: &SeekInfo{Whence: whence}, Logger: DiscardingLogger})
+		if err == nil || !strings.Contains(err.Error(), "unsupported whence") {
+			t.Fatalf("expected an unsupported whence error, got %v", err)
+		}
+	}
+
+	tailer, err := TailFile(testFile, Config{Follow: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	if err := tailer.Seek(SeekInfo{Whence: io.SeekCurrent}); err == nil {
+		t.Fatal("expected Seek to reject io.SeekCurrent")
+	}
+}
+
+func TestTail_SeekEnd(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("old\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, SeekEnd: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, tailer.Location, &SeekInfo{Offset: 0, Whence: io.SeekEnd})
+
+	time.Sleep(100 * time.Millisecond)
+	f.WriteString("new\n")
+	eq(t, recvLine(t, tailer).Text, "new")
+
+	// A rotated file is read from the start.
+	noError(t, os.Rename(testFile, testFile+".1"))
+	noError(t, os.WriteFile(testFile, []byte("rotated\n"), 0600))
+	eq(t, recvLine(t, tailer).Text, "rotated")
+
+	_, err = TailFile(testFile, Config{SeekEnd: true, Location: &SeekInfo{}, Logger: DiscardingLogger})
+	if err == nil {
+		t.Fatal("expected an error combining SeekEnd and Location")
+	}
+}
+
+func TestTail_AtomicRenameRotation(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		testFile := filepath.Join(t.TempDir(), "app.log")
+		noError(t, os.WriteFile(testFile, []byte("0\n"), 0600))
+
+		tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, Poll: poll, PollInterval: 10 * time.Millisecond, Logger: DiscardingLogger})
+		noError(t, err)
+		eq(t, recvLine(t, tailer).Text, "0")
+
+		// Each rotation writes the new file in full under a temporary
+		// name and renames it over the old one.
+		for i := 1; i <= 20; i++ {
+			tmp := testFile + ".tmp"
+			noError(t, os.WriteFile(tmp, []byte(fmt.Sprintf("%d\n", i)), 0600))
+			noError(t, os.Rename(tmp, testFile))
+			eq(t, recvLine(t, tailer).Text, strconv.Itoa(i))
+		}
+		select {
+		case line := <-tailer.Lines:
+			t.Fatalf("unexpected line %q", line.Text)
+		case <-time.After(100 * time.Millisecond):
+		}
+		cleanTailer(tailer)
+	}
+}
+
+func TestTail_ModTimeRegression(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "app.log")
+	noError(t, os.WriteFile(testFile, []byte("one\n"), 0600))
+
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, Poll: true, PollInterval: 10 * time.Millisecond, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, recvLine(t, tailer).Text, "one")
+	// The first poll notes the modification time.
+	time.Sleep(100 * time.Millisecond)
+
+	// A new file of the same size that reused the inode, as far as
+	// dev and inode tell: only its modification time is earlier.
+	noError(t, os.WriteFile(testFile, []byte("two\n"), 0600))
+	past := time.Now().Add(-time.Hour)
+	noError(t, os.Chtimes(testFile, past, past))
+	eq(t, recvLine(t, tailer).Text, "two")
+	eq(t, tailer.Stats().Reopens, uint64(1))
+}
+
+func TestTail_StopAtEOF(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\n")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	eq(t, recvLine(t, tailer).Text, "one")
+
+	// Written just before stopping: none of it may be lost.
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(f, "line %d\n", i)
+	}
+	stopped := make(chan error, 1)
+	go func() { stopped <- tailer.StopAtEOF() }()
+
+	var n int
+	for line := range tailer.Lines {
+		eq(t, line.Text, fmt.Sprintf("line %d", n))
+		n++
+	}
+	eq(t, n, 100)
+	noError(t, <-stopped)
+
+	// With nothing left to read it returns promptly.
+	tailer, err = TailFile(testFile, Config{Follow: true, MaxBufferedLines: 200, Logger: DiscardingLogger})
+	noError(t, err)
+	defer tailer.Cleanup()
+	for i := 0; i < 101; i++ {
+		recvLine(t, tailer)
+	}
+	start := time.Now()
+	noError(t, tailer.StopAtEOF())
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("StopAtEOF took %v", d)
+	}
+}
+
+func TestTail_LastNLines(t *testing.T) {
+	long := strings.Repeat("x", 5000)
+	for _, tc := range []struct {
+		content string
+		n       int
+		want    []string
+	}{
+		{"one\ntwo\nthree\n", 2, []string{"two", "three"}},
+		{"one\ntwo\nthree\n", 3, []string{"one", "two", "three"}},
+		{"one\ntwo\nthree\n", 10, []string{"one", "two", "three"}},
+		{"one\ntwo\nthree", 2, []string{"two", "three"}},
+		{"one\n\n\n", 2, []string{"", ""}},
+		{"one\n" + long + "\nthree\n", 2, []string{long, "three"}},
+		{"", 3, nil},
+	} {
+		testFile, f := testFile(t)
+		f.WriteString(tc.content)
+		f.Close()
+
+		tailer, err := TailFile(testFile, Config{LastNLines: tc.n, Logger: DiscardingLogger})
+		noError(t, err)
+		var got []string
+		for line := range tailer.Lines {
+			got = append(got, line.Text)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("LastNLines %d of %q: got %q, want %q", tc.n, tc.content, got, tc.want)
+		}
+		tailer.Cleanup()
+	}
+}
+
+func TestTail_LastNLinesFollow(t *testing.T) {
+	testFile, f := testFile(t)
+	defer f.Close()
+	f.WriteString("one\ntwo\nthr")
+
+	tailer, err := TailFile(testFile, Config{Follow: true, LastNLines: 2, Logger: DiscardingLogger})
+	noError(t, err)
+	defer cleanTailer(tailer)
+	eq(t, recvLine(t, tailer).Text, "two")
+	f.WriteString("ee\nfour\n")
+	eq(t, recvLine(t, tailer).Text, "three")
+	eq(t, recvLine(t, tailer).Text, "four")
+}
@@ -5,12 +5,39 @@ package tail
 import (
 	"os"
 
+	"github.com/tenebris-tech/tail/watch"
 	"github.com/tenebris-tech/tail/winfile"
 )
 
 func OpenFile(name string) (file *os.File, fileIdentifier string, err error) {
 	file, err = winfile.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, "", err
+	}
 
-	// TODO - use windows.GetFileInformationByHandle to get the file identifier based on volume, and fileId
-	return file, "", err
+	fileIdentifier, err = FileIdentifier(file)
+	if err != nil {
+		file.Close()
+		return nil, "", err
+	}
+	return file, fileIdentifier, nil
+}
+
+// FileIdentifier returns the identifier of an open file as reported in
+// Line.FileIdentifier, "volume:index" on Windows.
+func FileIdentifier(file *os.File) (string, error) {
+	return watch.FileIdentifier(file)
+}
+
+// FileIdentifierForPath returns the FileIdentifier of the file at name,
+// following symlinks, without opening it for reading, as the watchers
+// identify files.
+func FileIdentifierForPath(name string) (string, error) {
+	return watch.PathIdentifier(name)
+}
+
+// openNonBlocking is OpenFile, as Config.NonBlockingOpen has no effect on
+// Windows.
+func openNonBlocking(name string) (file *os.File, fileIdentifier string, err error) {
+	return OpenFile(name)
 }
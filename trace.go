@@ -0,0 +1,71 @@
+package tail
+
+// Tracer starts spans around significant tailer operations, such as opening
+// the file, reopening it after a rotation or truncation and replaying an
+// archive. It allows bridging to a tracing system like OpenTelemetry without
+// depending on it.
+type Tracer interface {
+	StartSpan(name string) Span
+}
+
+// Span is a single traced operation started by a Tracer.
+type Span interface {
+	SetAttr(key string, value interface{})
+	End()
+}
+
+// Names of the spans started by the tailer.
+const (
+	SpanOpen     = "tail.open"     // first open of the file, including the seek to Location
+	SpanRotate   = "tail.rotate"   // reopen after the file was moved or deleted
+	SpanTruncate = "tail.truncate" // reopen after the file was truncated
+	SpanReplay   = "tail.replay"   // replay of a compressed archive or rotated file
+)
+
+// Attributes set on spans started by the tailer.
+const (
+	AttrFile           = "file"
+	AttrFileIdentifier = "file.identifier"
+	AttrOffset         = "offset"
+	AttrError          = "error"
+)
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttr(string, interface{}) {}
+func (noopSpan) End()                        {}
+
+// startSpan starts a span with the file attribute set, or returns a no-op
+// span when no Tracer is configured.
+func (tail *Tail) startSpan(name string) Span {
+	if tail.Tracer == nil {
+		return noopSpan{}
+	}
+	span := tail.Tracer.StartSpan(name)
+	span.SetAttr(AttrFile, tail.Filename)
+	return span
+}
+
+// startReplay starts a span around replaying the archive or rotated file
+// name from the current offset.
+func (tail *Tail) startReplay(name string) Span {
+	span := tail.startSpan(SpanReplay)
+	span.SetAttr(AttrFile, name)
+	span.SetAttr(AttrOffset, tail.offset)
+	return span
+}
+
+// tracedReopen reopens the file within a span. The offset attribute records
+// how far the previous file had been read.
+func (tail *Tail) tracedReopen(name string) error {
+	span := tail.startSpan(name)
+	defer span.End()
+	span.SetAttr(AttrOffset, tail.offset)
+
+	if err := tail.reopen(); err != nil {
+		span.SetAttr(AttrError, err.Error())
+		return err
+	}
+	span.SetAttr(AttrFileIdentifier, tail.fileIdentifier)
+	return nil
+}
@@ -0,0 +1,121 @@
+package tail
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSpan struct {
+	name  string
+	attrs map[string]interface{}
+	ended bool
+}
+
+func (s *fakeSpan) SetAttr(key string, value interface{}) { s.attrs[key] = value }
+func (s *fakeSpan) End()                                  { s.ended = true }
+
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+func (ft *fakeTracer) StartSpan(name string) Span {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	span := &fakeSpan{name: name, attrs: map[string]interface{}{}}
+	ft.spans = append(ft.spans, span)
+	return span
+}
+
+func (ft *fakeTracer) started(name string) bool {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	for _, span := range ft.spans {
+		if span.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTail_TracerRotation(t *testing.T) {
+	testFile, f := testFile(t)
+	f.WriteString("hello\n")
+
+	tracer := &fakeTracer{}
+	tailer, err := TailFile(testFile, Config{Follow: true, ReOpen: true, Tracer: tracer, Logger: DiscardingLogger})
+	noError(t, err)
+
+	line := recvLine(t, tailer)
+	eq(t, line.Text, "hello")
+	oldID := line.FileIdentifier
+
+	f.Close()
+	noError(t, os.Rename(testFile, testFile+".1"))
+	// Wait for the tailer to notice the rotation before recreating the
+	// file, so the rotation isn't mistaken for a truncation.
+	deadline := time.Now().Add(5 * time.Second)
+	for !tracer.started(SpanRotate) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the rotation span")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	f, err = os.Create(testFile)
+	noError(t, err)
+	defer f.Close()
+	f.WriteString("world\n")
+
+	line = recvLine(t, tailer)
+	eq(t, line.Text, "world")
+	cleanTailer(tailer)
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	eq(t, len(tracer.spans), 2)
+
+	open := tracer.spans[0]
+	eq(t, open.name, SpanOpen)
+	eq(t, open.ended, true)
+	eq(t, open.attrs[AttrFile], testFile)
+	eq(t, open.attrs[AttrFileIdentifier], oldID)
+	eq(t, open.attrs[AttrOffset], int64(0))
+
+	rotate := tracer.spans[1]
+	eq(t, rotate.name, SpanRotate)
+	eq(t, rotate.ended, true)
+	eq(t, rotate.attrs[AttrFile], testFile)
+	eq(t, rotate.attrs[AttrOffset], int64(6))
+	eq(t, rotate.attrs[AttrFileIdentifier], line.FileIdentifier)
+}
+
+func TestTail_TracerReplay(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.log.gz")
+	f, err := os.Create(testFile)
+	noError(t, err)
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte("hello\nworld\n"))
+	noError(t, gz.Close())
+	noError(t, f.Close())
+
+	tracer := &fakeTracer{}
+	tailer, err := TailFile(testFile, Config{Gzip: true, Tracer: tracer, Logger: DiscardingLogger})
+	noError(t, err)
+	for range tailer.Lines {
+	}
+	noError(t, tailer.Wait())
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	eq(t, len(tracer.spans), 2)
+	eq(t, tracer.spans[0].name, SpanOpen)
+	replay := tracer.spans[1]
+	eq(t, replay.name, SpanReplay)
+	eq(t, replay.ended, true)
+	eq(t, replay.attrs[AttrFile], testFile)
+	eq(t, replay.attrs[AttrOffset], int64(0))
+}
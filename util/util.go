@@ -46,3 +46,17 @@ func PartitionString(s string, chunkSize int) []string {
 	}
 	return parts
 }
+
+// PartitionBytes partitions b into chunks of given size, with the last
+// chunk of variable size. The chunks share b's underlying array.
+func PartitionBytes(b []byte, chunkSize int) [][]byte {
+	if chunkSize <= 0 {
+		panic("invalid chunkSize")
+	}
+	parts := make([][]byte, 0, 1+len(b)/chunkSize)
+	for len(b) > chunkSize {
+		parts = append(parts, b[:chunkSize:chunkSize])
+		b = b[chunkSize:]
+	}
+	return append(parts, b)
+}
@@ -0,0 +1,99 @@
+package tail
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Validate reports the first contradictory or out of range option of
+// config. TailFile and the other constructors call it, so it only needs to
+// be called to check a Config ahead of time.
+func (config Config) Validate() error {
+	if len(config.Delimiter) > 1 {
+		return fmt.Errorf("tail: delimiter %q is not a single byte", config.Delimiter)
+	}
+	if config.ReOpen && !config.Follow {
+		return errors.New("tail: ReOpen cannot be set without Follow")
+	}
+	if config.SeekEnd && config.Location != nil {
+		return errors.New("tail: SeekEnd cannot be combined with Location")
+	}
+	if config.Location != nil {
+		if err := checkWhence(config.Location.Whence); err != nil {
+			return err
+		}
+	}
+	if config.LastNLines > 0 && (config.Location != nil || config.SeekEnd || config.SeekTime != nil) {
+		return errors.New("tail: LastNLines cannot be combined with Location, SeekEnd or SeekTime")
+	}
+	if config.SeekTime != nil && config.TimeParser == nil {
+		return errors.New("tail: SeekTime needs a TimeParser")
+	}
+	if config.SeekTime != nil && config.Gzip {
+		return errors.New("tail: SeekTime cannot be combined with Gzip")
+	}
+	if config.CheckpointPath != "" && config.PositionStore != nil {
+		return errors.New("tail: CheckpointPath cannot be combined with a PositionStore")
+	}
+	if config.OverflowPolicy == DropOldest && config.Channel != nil {
+		return errors.New("tail: DropOldest cannot be combined with a Channel")
+	}
+	if config.OverflowPolicy != Block && config.Channel == nil && config.MaxBufferedLines == 0 {
+		return errors.New("tail: DropNewest and DropOldest need MaxBufferedLines")
+	}
+	if config.Poll && config.Watcher != nil {
+		return errors.New("tail: Poll cannot be combined with a Watcher")
+	}
+	if config.Gzip && config.Follow {
+		return errors.New("tail: Gzip cannot be combined with Follow")
+	}
+	if config.TruncateLongLines && (config.JSONMode || config.Encoding != UTF8) {
+		return errors.New("tail: TruncateLongLines cannot be combined with JSONMode or a UTF-16 Encoding")
+	}
+	for _, n := range []struct {
+		name  string
+		value int64
+	}{
+		{"MaxLineSize", int64(config.MaxLineSize)},
+		{"MaxBufferedLines", int64(config.MaxBufferedLines)},
+		{"ReadBufferSize", int64(config.ReadBufferSize)},
+		{"SkipLines", int64(config.SkipLines)},
+		{"LastNLines", int64(config.LastNLines)},
+		{"MaxBytes", config.MaxBytes},
+		{"MaxReopenAttempts", int64(config.MaxReopenAttempts)},
+		{"CheckpointEveryNLines", int64(config.CheckpointEveryNLines)},
+	} {
+		if n.value < 0 {
+			return fmt.Errorf("tail: negative %s %d", n.name, n.value)
+		}
+	}
+	if config.MinPollInterval < 0 || config.MaxPollInterval < 0 {
+		return errors.New("tail: negative MinPollInterval or MaxPollInterval")
+	}
+	if config.MaxPollInterval > 0 && config.MaxPollInterval < config.MinPollInterval {
+		return fmt.Errorf("tail: MaxPollInterval %v is less than MinPollInterval %v", config.MaxPollInterval, config.MinPollInterval)
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"DeletionConfirmDelay", config.DeletionConfirmDelay},
+		{"IdleTimeout", config.IdleTimeout},
+		{"WaitForFileTimeout", config.WaitForFileTimeout},
+		{"ReopenBackoff", config.ReopenBackoff},
+		{"PollInterval", config.PollInterval},
+		{"MaxWaitBackoff", config.MaxWaitBackoff},
+		{"EventCoalesceWindow", config.EventCoalesceWindow},
+		{"MaxRuntime", config.MaxRuntime},
+		{"CheckpointInterval", config.CheckpointInterval},
+	} {
+		if d.value < 0 {
+			return fmt.Errorf("tail: negative %s %v", d.name, d.value)
+		}
+	}
+	if config.RateLimit < 0 {
+		return fmt.Errorf("tail: negative RateLimit %v", config.RateLimit)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package tail
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tenebris-tech/tail/watch"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		config Config
+		want   string
+	}{
+		{Config{Delimiter: "ab"}, "tail: delimiter"},
+		{Config{SeekEnd: true, Location: &SeekInfo{}}, "SeekEnd cannot be combined with Location"},
+		{Config{Location: &SeekInfo{Whence: 1}}, "unsupported whence"},
+		{Config{SeekTime: &now}, "SeekTime needs a TimeParser"},
+		{Config{SeekTime: &now, TimeParser: func(string) (time.Time, bool) { return now, true }, Gzip: true}, "SeekTime cannot be combined with Gzip"},
+		{Config{CheckpointPath: "x", PositionStore: CheckpointFile{}}, "CheckpointPath cannot be combined with a PositionStore"},
+		{Config{Poll: true, Watcher: watch.NewPollingFileWatcher("x")}, "Poll cannot be combined with a Watcher"},
+		{Config{MaxLineSize: -1}, "negative MaxLineSize"},
+		{Config{MaxBufferedLines: -1}, "negative MaxBufferedLines"},
+		{Config{ReadBufferSize: -1}, "negative ReadBufferSize"},
+		{Config{SkipLines: -1}, "negative SkipLines"},
+		{Config{LastNLines: -1}, "negative LastNLines"},
+		{Config{OverflowPolicy: DropOldest, Channel: make(chan *Line, 1)}, "DropOldest cannot be combined with a Channel"},
+		{Config{LastNLines: 1, SeekEnd: true}, "LastNLines cannot be combined"},
+		{Config{MaxBytes: -1}, "negative MaxBytes"},
+		{Config{MaxReopenAttempts: -1}, "negative MaxReopenAttempts"},
+		{Config{RateLimit: -1}, "negative RateLimit"},
+		{Config{DeletionConfirmDelay: -1}, "negative DeletionConfirmDelay"},
+		{Config{IdleTimeout: -1}, "negative IdleTimeout"},
+		{Config{WaitForFileTimeout: -1}, "negative WaitForFileTimeout"},
+		{Config{ReopenBackoff: -1}, "negative ReopenBackoff"},
+		{Config{PollInterval: -1}, "negative PollInterval"},
+		{Config{MaxWaitBackoff: -1}, "negative MaxWaitBackoff"},
+		{Config{EventCoalesceWindow: -1}, "negative EventCoalesceWindow"},
+		{Config{MaxRuntime: -1}, "negative MaxRuntime"},
+		{Config{CheckpointInterval: -1}, "negative CheckpointInterval"},
+		{Config{CheckpointEveryNLines: -1}, "negative CheckpointEveryNLines"},
+		{Config{Gzip: true, Follow: true}, "Gzip cannot be combined with Follow"},
+		{Config{TruncateLongLines: true, JSONMode: true}, "TruncateLongLines cannot be combined"},
+		{Config{TruncateLongLines: true, Encoding: UTF16LE}, "TruncateLongLines cannot be combined"},
+		{Config{ReOpen: true}, "ReOpen cannot be set without Follow"},
+		{Config{OverflowPolicy: DropNewest}, "need MaxBufferedLines"},
+		{Config{MaxPollInterval: -1}, "negative MinPollInterval or MaxPollInterval"},
+		{Config{MinPollInterval: time.Second, MaxPollInterval: time.Millisecond}, "less than MinPollInterval"},
+	} {
+		err := tc.config.Validate()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Validate() = %v, want an error containing %q", err, tc.want)
+		}
+		if _, err := TailFile("x", tc.config); err == nil {
+			t.Errorf("TailFile accepted a config failing with %q", tc.want)
+		}
+	}
+
+	noError(t, Config{Follow: true, ReOpen: true, MaxLineSize: 10, Location: &SeekInfo{}}.Validate())
+}
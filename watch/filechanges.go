@@ -1,5 +1,31 @@
 package watch
 
+import "strconv"
+
+// ChangeType is a kind of change to a watched file.
+type ChangeType int
+
+const (
+	None ChangeType = iota
+	Modified
+	Truncated
+	Deleted
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case None:
+		return "None"
+	case Modified:
+		return "Modified"
+	case Truncated:
+		return "Truncated"
+	case Deleted:
+		return "Deleted"
+	}
+	return "ChangeType(" + strconv.Itoa(int(c)) + ")"
+}
+
 type FileChanges struct {
 	Modified  chan bool // Channel to get notified of modifications
 	Truncated chan bool // Channel to get notified of truncations
@@ -23,6 +49,19 @@ func (fc *FileChanges) NotifyDeleted() {
 	sendOnlyIfEmpty(fc.Deleted)
 }
 
+// Notify notifies a change of the given type, for FileWatcher
+// implementations that detect changes in one place. None is ignored.
+func (fc *FileChanges) Notify(c ChangeType) {
+	switch c {
+	case Modified:
+		fc.NotifyModified()
+	case Truncated:
+		fc.NotifyTruncated()
+	case Deleted:
+		fc.NotifyDeleted()
+	}
+}
+
 // sendOnlyIfEmpty sends on a bool channel only if the channel has no
 // backlog to be read by other goroutines. This concurrency pattern
 // can be used to notify other goroutines if and only if they are
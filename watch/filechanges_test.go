@@ -0,0 +1,35 @@
+package watch
+
+import "testing"
+
+func TestChangeType_String(t *testing.T) {
+	for c, want := range map[ChangeType]string{
+		None:          "None",
+		Modified:      "Modified",
+		Truncated:     "Truncated",
+		Deleted:       "Deleted",
+		ChangeType(9): "ChangeType(9)",
+	} {
+		if got := c.String(); got != want {
+			t.Errorf("ChangeType(%d).String() = %q, want %q", int(c), got, want)
+		}
+	}
+}
+
+func TestFileChanges_Notify(t *testing.T) {
+	fc := NewFileChanges()
+	fc.Notify(None)
+	fc.Notify(Truncated)
+	select {
+	case <-fc.Truncated:
+	default:
+		t.Fatal("Truncated not notified")
+	}
+	select {
+	case <-fc.Modified:
+		t.Fatal("unexpected Modified")
+	case <-fc.Deleted:
+		t.Fatal("unexpected Deleted")
+	default:
+	}
+}
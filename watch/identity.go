@@ -0,0 +1,9 @@
+package watch
+
+import "os"
+
+// fileIdentity tells a file apart from another later found at its path.
+type fileIdentity struct {
+	fi os.FileInfo
+	id string // volume:index on Windows
+}
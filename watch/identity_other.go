@@ -0,0 +1,13 @@
+//go:build !windows
+
+package watch
+
+import "os"
+
+func identify(name string, fi os.FileInfo) (fileIdentity, error) {
+	return fileIdentity{fi: fi}, nil
+}
+
+func (a fileIdentity) same(b fileIdentity) bool {
+	return os.SameFile(a.fi, b.fi)
+}
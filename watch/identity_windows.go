@@ -0,0 +1,58 @@
+//go:build windows
+
+package watch
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// identify reads the volume serial number and file index of the file at
+// name right away. os.SameFile only does so when called, by which time a
+// rotated file has been replaced by the new one at the same path.
+func identify(name string, fi os.FileInfo) (fileIdentity, error) {
+	id, err := PathIdentifier(name)
+	if err != nil {
+		return fileIdentity{}, err
+	}
+	return fileIdentity{fi: fi, id: id}, nil
+}
+
+// PathIdentifier returns the "volume:index" identifier of the file at name,
+// following symlinks. The handle it is read through has no access rights
+// and shares everything, so it does not get in the way of writers, renames
+// or deletes.
+func PathIdentifier(name string) (string, error) {
+	path, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return "", err
+	}
+	h, err := windows.CreateFile(path, 0,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return "", &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	defer windows.CloseHandle(h)
+	return handleIdentifier(h, name)
+}
+
+// FileIdentifier is PathIdentifier for a file that is already open.
+func FileIdentifier(file *os.File) (string, error) {
+	return handleIdentifier(windows.Handle(file.Fd()), file.Name())
+}
+
+func handleIdentifier(h windows.Handle, name string) (string, error) {
+	var info windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(h, &info); err != nil {
+		return "", &os.PathError{Op: "GetFileInformationByHandle", Path: name, Err: err}
+	}
+	index := uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow)
+	return fmt.Sprintf("%d:%d", info.VolumeSerialNumber, index), nil
+}
+
+func (a fileIdentity) same(b fileIdentity) bool {
+	return a.id == b.id
+}
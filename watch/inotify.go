@@ -18,10 +18,26 @@ import (
 type InotifyFileWatcher struct {
 	Filename string
 	Size     int64
+
+	// WatchLink also watches the directory entry of Filename, so that a
+	// symlink being repointed is reported as a deletion. Otherwise only
+	// the file the symlink resolved to is watched.
+	WatchLink bool
+
+	// WatchDir also watches the parent directory, so that a file created
+	// or moved to Filename is noticed at once, both while waiting for it
+	// to exist and after the watched file was rotated away. If the
+	// directory cannot be watched, it is polled for as without WatchDir.
+	WatchDir bool
+
+	dirWatched bool // the parent directory is watched by ChangeEvents
+	// MaxBackoff, when greater than POLL_DURATION, makes BlockUntilExists
+	// double the time between checks after each one, up to MaxBackoff.
+	MaxBackoff time.Duration
 }
 
 func NewInotifyFileWatcher(filename string) *InotifyFileWatcher {
-	fw := &InotifyFileWatcher{filepath.Clean(filename), 0}
+	fw := &InotifyFileWatcher{Filename: filepath.Clean(filename)}
 	return fw
 }
 
@@ -31,6 +47,13 @@ func (fw *InotifyFileWatcher) BlockUntilExists(t *tomb.Tomb) error {
 	// but the results of os.Stat and ionotify do change because they both follow links.
 
 	// Instead, just do a blocking check every POLL_DURATION until the file exists.
+	// With WatchDir, a creation in the parent directory ends the wait early.
+	var created <-chan fsnotify.Event
+	if fw.WatchDir && WatchCreate(fw.Filename) == nil {
+		created = Events(fw.Filename)
+		defer RemoveWatchCreate(fw.Filename)
+	}
+	wait := POLL_DURATION
 	for {
 		if _, err := os.Stat(fw.Filename); err == nil {
 			return nil
@@ -38,7 +61,13 @@ func (fw *InotifyFileWatcher) BlockUntilExists(t *tomb.Tomb) error {
 			return err
 		}
 		select {
-		case <-time.After(POLL_DURATION):
+		case _, ok := <-created:
+			if !ok {
+				created = nil
+			}
+			continue
+		case <-time.After(wait):
+			wait = nextBackoff(wait, fw.MaxBackoff)
 			continue
 		case <-t.Dying():
 			return tomb.ErrDying
@@ -51,10 +80,31 @@ func (fw *InotifyFileWatcher) ChangeEvents(t *tomb.Tomb, pos int64) (*FileChange
 	if err != nil {
 		return nil, err
 	}
+	fw.dirWatched = false
+	if fw.WatchLink || fw.WatchDir {
+		if err := WatchCreate(fw.Filename); err == nil {
+			fw.dirWatched = true
+		} else if fw.WatchLink {
+			_ = RemoveWatch(fw.Filename)
+			return nil, err
+		}
+	}
 
 	changes := NewFileChanges()
 	fw.Size = pos
 
+	// The file may have been written to before the watch was added, in
+	// which case no event will arrive for those writes.
+	watched, err := os.Stat(fw.Filename)
+	if err == nil {
+		if watched.Size() < pos {
+			fw.Size = watched.Size()
+			changes.NotifyTruncated()
+		} else if watched.Size() > pos {
+			changes.NotifyModified()
+		}
+	}
+
 	go func() {
 
 		events := Events(fw.Filename)
@@ -68,11 +118,11 @@ func (fw *InotifyFileWatcher) ChangeEvents(t *tomb.Tomb, pos int64) (*FileChange
 			select {
 			case evt, ok = <-events:
 				if !ok {
-					_ = RemoveWatch(fw.Filename)
+					fw.removeWatch()
 					return
 				}
 			case <-t.Dying():
-				_ = RemoveWatch(fw.Filename)
+				fw.removeWatch()
 				return
 			}
 
@@ -81,7 +131,12 @@ func (fw *InotifyFileWatcher) ChangeEvents(t *tomb.Tomb, pos int64) (*FileChange
 				fallthrough
 
 			case evt.Op&fsnotify.Rename == fsnotify.Rename:
-				_ = RemoveWatch(fw.Filename)
+				fallthrough
+
+			// Only seen with WatchLink or WatchDir: the name now refers
+			// to another file.
+			case evt.Op&fsnotify.Create == fsnotify.Create:
+				fw.removeWatch()
 				changes.NotifyDeleted()
 				return
 
@@ -93,13 +148,21 @@ func (fw *InotifyFileWatcher) ChangeEvents(t *tomb.Tomb, pos int64) (*FileChange
 				fi, err := os.Stat(fw.Filename)
 				if err != nil {
 					if os.IsNotExist(err) {
-						_ = RemoveWatch(fw.Filename)
+						fw.removeWatch()
 						changes.NotifyDeleted()
 						return
 					}
 					// XXX: report this error back to the user
 					util.Fatal("Failed to stat file %v: %v", fw.Filename, err)
 				}
+				if watched != nil && !os.SameFile(watched, fi) {
+					// The watch follows the inode, so once another file
+					// has been renamed over the name (IN_ATTRIB as the
+					// link count drops) no more events would arrive.
+					fw.removeWatch()
+					changes.NotifyDeleted()
+					return
+				}
 				fw.Size = fi.Size()
 
 				if prevSize > 0 && prevSize > fw.Size {
@@ -113,3 +176,10 @@ func (fw *InotifyFileWatcher) ChangeEvents(t *tomb.Tomb, pos int64) (*FileChange
 
 	return changes, nil
 }
+
+func (fw *InotifyFileWatcher) removeWatch() {
+	_ = RemoveWatch(fw.Filename)
+	if fw.dirWatched {
+		_ = RemoveWatchCreate(fw.Filename)
+	}
+}
@@ -0,0 +1,124 @@
+//go:build linux
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/tomb.v1"
+)
+
+func TestInotifyFileWatcher_BlockUntilExistsWatchDir(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "later.log")
+	fw := NewInotifyFileWatcher(name)
+	fw.WatchDir = true
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(name, nil, 0600)
+	}()
+	var tb tomb.Tomb
+	start := time.Now()
+	if err := fw.BlockUntilExists(&tb); err != nil {
+		t.Fatal(err)
+	}
+	// Without WatchDir the file would only be checked for again after
+	// POLL_DURATION.
+	if elapsed := time.Since(start); elapsed >= POLL_DURATION {
+		t.Fatalf("creation noticed after %v", elapsed)
+	}
+}
+
+func TestWatchCreate_FailureKeepsFileWatch(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(name, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Watch(name); err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveWatch(name)
+	events := Events(name)
+
+	// The file stays watched, but its directory can no longer be.
+	moved := dir + ".moved"
+	if err := os.Rename(dir, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := WatchCreate(name); err == nil {
+		t.Fatal("expected WatchCreate to fail")
+	}
+	if Events(name) != events {
+		t.Fatal("failed WatchCreate removed the file's events")
+	}
+
+	f, err := os.OpenFile(filepath.Join(moved, "app.log"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString("hello\n")
+	select {
+	case <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event after WatchCreate failed")
+	}
+}
+
+func TestInotifyFileWatcher_WatchDirFallback(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(name, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Another watcher of the name keeps the file watched while its
+	// directory is briefly gone, so that only the directory watch fails.
+	if err := Watch(name); err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveWatch(name)
+	moved := dir + ".moved"
+	if err := os.Rename(dir, moved); err != nil {
+		t.Fatal(err)
+	}
+
+	fw := NewInotifyFileWatcher(name)
+	fw.WatchDir = true
+	var tb tomb.Tomb
+	defer tb.Done()
+	defer tb.Kill(nil)
+	changes, err := fw.ChangeEvents(&tb, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fw.dirWatched {
+		t.Fatal("expected the directory watch to fail")
+	}
+	if err := os.Rename(moved, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString("hello\n")
+	select {
+	case <-changes.Modified:
+	case <-changes.Deleted:
+		t.Fatal("unexpected Deleted")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no Modified without the directory watch")
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"syscall"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/tenebris-tech/tail/util"
 )
 
 type InotifyTracker struct {
@@ -23,6 +22,7 @@ type InotifyTracker struct {
 	watch     chan *watchInfo
 	remove    chan *watchInfo
 	error     chan error
+	initErr   error // set if the fsnotify.Watcher could not be created
 }
 
 type watchInfo struct {
@@ -50,6 +50,10 @@ var (
 			remove:    make(chan *watchInfo),
 			error:     make(chan error),
 		}
+		shared.watcher, shared.initErr = fsnotify.NewWatcher()
+		if shared.initErr != nil {
+			return
+		}
 		go shared.run()
 	}
 
@@ -76,6 +80,10 @@ func watch(winfo *watchInfo) error {
 	// start running the shared InotifyTracker if not already running
 	once.Do(goRun)
 
+	if shared.initErr != nil {
+		return shared.initErr
+	}
+
 	winfo.fname = filepath.Clean(winfo.fname)
 	shared.watch <- winfo
 	return <-shared.error
@@ -99,6 +107,9 @@ func RemoveWatchCreate(fname string) error {
 func remove(winfo *watchInfo) error {
 	// start running the shared InotifyTracker if not already running
 	once.Do(goRun)
+	if shared.initErr != nil {
+		return nil
+	}
 
 	winfo.fname = filepath.Clean(winfo.fname)
 	shared.mux.Lock()
@@ -134,10 +145,14 @@ func (shared *InotifyTracker) addWatch(winfo *watchInfo) error {
 	shared.mux.Lock()
 	defer shared.mux.Unlock()
 
-	if shared.chans[winfo.fname] == nil {
+	// A file and a Create watch for it share these, so a failure must only
+	// undo what this call added.
+	newChan := shared.chans[winfo.fname] == nil
+	if newChan {
 		shared.chans[winfo.fname] = make(chan fsnotify.Event)
 	}
-	if shared.done[winfo.fname] == nil {
+	newDone := shared.done[winfo.fname] == nil
+	if newDone {
 		shared.done[winfo.fname] = make(chan bool)
 	}
 
@@ -152,10 +167,17 @@ func (shared *InotifyTracker) addWatch(winfo *watchInfo) error {
 	if shared.watchNums[fname] == 0 {
 		err = shared.watcher.Add(fname)
 	}
-	if err == nil {
-		shared.watchNums[fname]++
+	if err != nil {
+		if newChan {
+			delete(shared.chans, winfo.fname)
+		}
+		if newDone {
+			delete(shared.done, winfo.fname)
+		}
+		return err
 	}
-	return err
+	shared.watchNums[fname]++
+	return nil
 }
 
 // removeWatch calls fsnotify.RemoveWatch for the input filename and closes the
@@ -174,6 +196,11 @@ func (shared *InotifyTracker) removeWatch(winfo *watchInfo) error {
 		// Watch for new files to be created in the parent directory.
 		fname = filepath.Dir(fname)
 	}
+	if shared.watchNums[fname] == 0 {
+		// Not watched, e.g. the watch failed or the file was polled.
+		shared.mux.Unlock()
+		return nil
+	}
 	shared.watchNums[fname]--
 	watchNum := shared.watchNums[fname]
 	if watchNum == 0 {
@@ -213,12 +240,6 @@ func (shared *InotifyTracker) sendEvent(event fsnotify.Event) {
 // run starts the goroutine in which the shared struct reads events from its
 // Watcher's Event channel and sends the events to the appropriate Tail.
 func (shared *InotifyTracker) run() {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		util.Fatal("failed to create Watcher")
-	}
-	shared.watcher = watcher
-
 	for {
 		select {
 		case winfo := <-shared.watch:
@@ -6,6 +6,7 @@ package watch
 import (
 	"os"
 	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/tenebris-tech/tail/util"
@@ -16,16 +17,77 @@ import (
 type PollingFileWatcher struct {
 	Filename string
 	Size     int64
+	Interval time.Duration // time between polls, POLL_DURATION if not positive
+
+	// MaxBackoff, when greater than Interval, makes BlockUntilExists
+	// double the time between checks after each one, up to MaxBackoff.
+	MaxBackoff time.Duration
+
+	// MaxInterval, when greater than Interval, makes the time between
+	// polls double after each poll that finds no change, up to
+	// MaxInterval. It drops back to Interval after each change.
+	MaxInterval time.Duration
+
+	// DeletionConfirmDelay, when positive, makes a failure to stat the
+	// file that looks like a deletion, or a permission error as seen on
+	// some overlay and read-only mounts, be checked again after this
+	// delay before Deleted is notified. The file is still watched if the
+	// second check succeeds.
+	DeletionConfirmDelay time.Duration
+
+	// Clock is the source of time for polls and waits, the wall clock if
+	// nil.
+	Clock Clock
+
+	stat func(name string) (os.FileInfo, error) // os.Stat if nil; set by tests
+
+	reload atomic.Int64  // Interval set by SetInterval, if nonzero
+	wake   chan struct{} // signaled by SetInterval
 }
 
+// NewPollingFileWatcher creates a watcher that polls filename every
+// POLL_DURATION; set Interval before use to poll at another rate.
 func NewPollingFileWatcher(filename string) *PollingFileWatcher {
-	fw := &PollingFileWatcher{filename, 0}
+	fw := &PollingFileWatcher{Filename: filename, Interval: POLL_DURATION, wake: make(chan struct{}, 1)}
 	return fw
 }
 
+// SetInterval changes the time between polls of a watcher that may already
+// be polling; the wait for the next poll starts over. A non-positive
+// interval means POLL_DURATION.
+func (fw *PollingFileWatcher) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = POLL_DURATION
+	}
+	fw.reload.Store(int64(interval))
+	select {
+	case fw.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (fw *PollingFileWatcher) interval() time.Duration {
+	if d := fw.reload.Load(); d != 0 {
+		return time.Duration(d)
+	}
+	if fw.Interval <= 0 {
+		return POLL_DURATION
+	}
+	return fw.Interval
+}
+
+func (fw *PollingFileWatcher) clock() Clock {
+	if fw.Clock == nil {
+		return realClock{}
+	}
+	return fw.Clock
+}
+
+// POLL_DURATION is the default polling interval.
 var POLL_DURATION time.Duration
 
 func (fw *PollingFileWatcher) BlockUntilExists(t *tomb.Tomb) error {
+	wait := fw.interval()
 	for {
 		if _, err := os.Stat(fw.Filename); err == nil {
 			return nil
@@ -33,7 +95,8 @@ func (fw *PollingFileWatcher) BlockUntilExists(t *tomb.Tomb) error {
 			return err
 		}
 		select {
-		case <-time.After(POLL_DURATION):
+		case <-fw.clock().After(wait):
+			wait = nextBackoff(wait, fw.MaxBackoff)
 			continue
 		case <-t.Dying():
 			return tomb.ErrDying
@@ -46,6 +109,10 @@ func (fw *PollingFileWatcher) ChangeEvents(t *tomb.Tomb, pos int64) (*FileChange
 	if err != nil {
 		return nil, err
 	}
+	origID, err := identify(fw.Filename, origFi)
+	if err != nil {
+		return nil, err
+	}
 
 	changes := NewFileChanges()
 	var prevModTime time.Time
@@ -55,21 +122,40 @@ func (fw *PollingFileWatcher) ChangeEvents(t *tomb.Tomb, pos int64) (*FileChange
 
 	fw.Size = pos
 
+	clock := fw.clock()
 	go func() {
 		prevSize := fw.Size
+		wait := fw.interval()
 		for {
+			timer, stop := clock.NewTimer(wait)
 			select {
 			case <-t.Dying():
+				stop()
 				return
-			default:
+			case <-fw.wake:
+				stop()
+				wait = fw.interval()
+				continue
+			case <-timer:
+			}
+			// Changes below start over at Interval.
+			quiet := wait
+			wait = fw.interval()
+
+			fi, id, err := fw.statFile()
+			if err != nil && fw.DeletionConfirmDelay > 0 && (os.IsNotExist(err) || os.IsPermission(err)) {
+				select {
+				case <-clock.After(fw.DeletionConfirmDelay):
+				case <-t.Dying():
+					return
+				}
+				fi, id, err = fw.statFile()
 			}
-
-			time.Sleep(POLL_DURATION)
-			fi, err := os.Stat(fw.Filename)
 			if err != nil {
 				// Windows cannot delete a file if a handle is still open (tail keeps one open)
 				// so it gives access denied to anything trying to read it until all handles are released.
-				if os.IsNotExist(err) || (runtime.GOOS == "windows" && os.IsPermission(err)) {
+				// A confirmed permission error is taken as a deletion too.
+				if os.IsNotExist(err) || (os.IsPermission(err) && (runtime.GOOS == "windows" || fw.DeletionConfirmDelay > 0)) {
 					// File does not exist (has been deleted).
 					changes.NotifyDeleted()
 					return
@@ -80,7 +166,15 @@ func (fw *PollingFileWatcher) ChangeEvents(t *tomb.Tomb, pos int64) (*FileChange
 			}
 
 			// File got moved/renamed?
-			if !os.SameFile(origFi, fi) {
+			if !origID.same(id) {
+				changes.NotifyDeleted()
+				return
+			}
+
+			// A modification time going backwards means the inode was
+			// reused by a new file under rapid rotation.
+			modTime := fi.ModTime()
+			if !prevModTime.IsZero() && modTime.Before(prevModTime) {
 				changes.NotifyDeleted()
 				return
 			}
@@ -89,29 +183,44 @@ func (fw *PollingFileWatcher) ChangeEvents(t *tomb.Tomb, pos int64) (*FileChange
 			fw.Size = fi.Size()
 			if prevSize > 0 && prevSize > fw.Size {
 				changes.NotifyTruncated()
-				prevSize = fw.Size
+				prevSize, prevModTime = fw.Size, modTime
 				continue
 			}
 			// File got bigger?
 			if prevSize > 0 && prevSize < fw.Size {
 				changes.NotifyModified()
-				prevSize = fw.Size
+				prevSize, prevModTime = fw.Size, modTime
 				continue
 			}
 			prevSize = fw.Size
 
 			// File was appended to (changed)?
-			modTime := fi.ModTime()
 			if modTime != prevModTime {
 				prevModTime = modTime
 				changes.NotifyModified()
+				continue
 			}
+			wait = nextBackoff(quiet, fw.MaxInterval)
 		}
 	}()
 
 	return changes, nil
 }
 
+// statFile returns the FileInfo and identity of the file.
+func (fw *PollingFileWatcher) statFile() (os.FileInfo, fileIdentity, error) {
+	stat := fw.stat
+	if stat == nil {
+		stat = os.Stat
+	}
+	fi, err := stat(fw.Filename)
+	if err != nil {
+		return nil, fileIdentity{}, err
+	}
+	id, err := identify(fw.Filename, fi)
+	return fi, id, err
+}
+
 func init() {
 	POLL_DURATION = 250 * time.Millisecond
 }
@@ -0,0 +1,200 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/tomb.v1"
+)
+
+func TestPollingFileWatcher_ModTimeRegression(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.log")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString("hello\n")
+
+	var tb tomb.Tomb
+	defer tb.Kill(nil)
+	fw := NewPollingFileWatcher(name)
+	fw.Interval = 10 * time.Millisecond
+	changes, err := fw.ChangeEvents(&tb, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(want <-chan bool, what string) {
+		t.Helper()
+		select {
+		case <-want:
+		case <-changes.Deleted:
+			t.Fatalf("got Deleted, want %s", what)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+
+	// Appends advance the modification time and are plain modifications.
+	expect(changes.Modified, "Modified")
+	f.WriteString("world\n")
+	expect(changes.Modified, "Modified")
+
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(name, past, past); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes.Deleted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Deleted")
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	for _, tc := range []struct{ d, max, want time.Duration }{
+		{100, 0, 100},
+		{100, 50, 100},
+		{100, 150, 150},
+		{100, 1000, 200},
+		{800, 1000, 1000},
+	} {
+		if got := nextBackoff(tc.d, tc.max); got != tc.want {
+			t.Errorf("nextBackoff(%v, %v) = %v, want %v", tc.d, tc.max, got, tc.want)
+		}
+	}
+}
+
+func TestPollingFileWatcher_BlockUntilExistsBackoff(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.log")
+	fw := NewPollingFileWatcher(name)
+	fw.Interval = 10 * time.Millisecond
+	fw.MaxBackoff = 80 * time.Millisecond
+
+	var tb tomb.Tomb
+	defer tb.Kill(nil)
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() { done <- fw.BlockUntilExists(&tb) }()
+
+	// Checks at 10, 30, 70, 150 and 230ms: the file created at 160ms is
+	// found at 230ms, not at 160ms as with a fixed interval.
+	time.Sleep(160 * time.Millisecond)
+	if err := os.WriteFile(name, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("file found after %v, expected the wait to back off", elapsed)
+	}
+}
+
+func TestPollingFileWatcher_MaxInterval(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.log")
+	if err := os.WriteFile(name, []byte("hello\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var tb tomb.Tomb
+	defer tb.Kill(nil)
+	fw := NewPollingFileWatcher(name)
+	fw.Interval = 10 * time.Millisecond
+	fw.MaxInterval = 160 * time.Millisecond
+	changes, err := fw.ChangeEvents(&tb, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified := func() time.Time {
+		t.Helper()
+		select {
+		case <-changes.Modified:
+			return time.Now()
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for Modified")
+		}
+		return time.Time{}
+	}
+	// The first poll notes the modification time.
+	modified()
+
+	// Polls 20, 40, 80, 160 and 160ms apart: a write 400ms later is only
+	// seen at the sixth poll, about 70ms after it.
+	time.Sleep(400 * time.Millisecond)
+	f.WriteString("world\n")
+	start := time.Now()
+	if elapsed := modified().Sub(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("write seen after %v, expected polling to have slowed down", elapsed)
+	}
+
+	// The change brings polling back to Interval.
+	f.WriteString("again\n")
+	modified()
+}
+
+func TestPollingFileWatcher_DeletionConfirmDelay(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		failures int32
+		deleted  bool
+	}{
+		{"transient", 1, false},
+		{"persistent", 1 << 30, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "test.log")
+			if err := os.WriteFile(name, []byte("hello\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			var tb tomb.Tomb
+			defer tb.Kill(nil)
+			fw := NewPollingFileWatcher(name)
+			fw.Interval = 10 * time.Millisecond
+			fw.DeletionConfirmDelay = 10 * time.Millisecond
+			var polls atomic.Int32
+			fw.stat = func(name string) (os.FileInfo, error) {
+				// The second poll fails.
+				if n := polls.Add(1); n >= 2 && n < 2+tc.failures {
+					return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrPermission}
+				}
+				return os.Stat(name)
+			}
+			changes, err := fw.ChangeEvents(&tb, 6)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case <-changes.Deleted:
+				if !tc.deleted {
+					t.Fatal("got Deleted for a transient permission error")
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tc.deleted {
+					t.Fatal("timed out waiting for Deleted")
+				}
+			}
+		})
+	}
+}
+
+func TestPollingFileWatcher_DefaultInterval(t *testing.T) {
+	if d := NewPollingFileWatcher("x").interval(); d != POLL_DURATION {
+		t.Fatalf("expected POLL_DURATION, got %v", d)
+	}
+	// A watcher built without the constructor polls at the default rate too.
+	if d := (&PollingFileWatcher{Filename: "x"}).interval(); d != POLL_DURATION {
+		t.Fatalf("expected POLL_DURATION for a zero Interval, got %v", d)
+	}
+}
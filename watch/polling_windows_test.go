@@ -0,0 +1,42 @@
+//go:build windows
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/tomb.v1"
+)
+
+func TestPollingFileWatcher_RenameRotation(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "rotate.log")
+	if err := os.WriteFile(name, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var tb tomb.Tomb
+	defer tb.Done()
+	defer tb.Kill(nil)
+	fw := NewPollingFileWatcher(name)
+	fw.Interval = 10 * time.Millisecond
+	changes, err := fw.ChangeEvents(&tb, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte("new\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes.Deleted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rotation not detected")
+	}
+}
@@ -3,7 +3,11 @@
 
 package watch
 
-import "gopkg.in/tomb.v1"
+import (
+	"time"
+
+	"gopkg.in/tomb.v1"
+)
 
 // FileWatcher monitors file-level events.
 type FileWatcher interface {
@@ -18,3 +22,15 @@ type FileWatcher interface {
 	// the caller to pass their current offset in the file.
 	ChangeEvents(*tomb.Tomb, int64) (*FileChanges, error)
 }
+
+// nextBackoff returns the wait after d when backing off up to max. It stays
+// at d if max is not greater.
+func nextBackoff(d, max time.Duration) time.Duration {
+	if max <= d {
+		return d
+	}
+	if d *= 2; d > max {
+		return max
+	}
+	return d
+}